@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"uniswap-simulator/lib/result"
+	"uniswap-simulator/lib/sweep"
+)
+
+// printCapacity prints return, fee share and price impact cost of the runs by their start value in USD
+func printCapacity(results []result.RunResult) {
+	sorted := make([]result.RunResult, 0, len(results))
+	for _, r := range results {
+		if r.Error == "" {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Parameters[sweep.Notional] < sorted[j].Parameters[sweep.Notional]
+	})
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "start value\treturn\tfee share\tliquidation cost\tparameters\t")
+	for _, r := range sorted {
+		parameters := make(sweep.Point, len(r.Parameters))
+		for name, value := range r.Parameters {
+			if name != sweep.Notional {
+				parameters[name] = value
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%.4f%%\t%.4f%%\t%.4f%%\t%v\t\n", r.StartAmount, r.Return*100, r.FeeShare*100, r.LiquidationCost*100, map[string]float64(parameters))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+	"uniswap-simulator/lib/config"
+	"uniswap-simulator/lib/executor"
+	strat "uniswap-simulator/lib/strategy"
+	"uniswap-simulator/lib/sweep"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestScale(t *testing.T) {
+	for _, test := range []struct {
+		amount string
+		factor float64
+		want   string
+	}{
+		{"1000000", 1, "1000000"},
+		{"1000000", 0.5, "500000"},
+		// Rounded down
+		{"3", 0.5, "1"},
+		{"999", 0.001, "0"},
+		{"1000000", 1e-7, "0"},
+		{"366874042000000", 1e12, "366874042000000000000000000"},
+		{"366874042000000", 100_000_000, "36687404200000000000000"},
+		{"1", 1e30, "1000000000000000019884624838656"},
+	} {
+		amount, _ := new(big.Int).SetString(test.amount, 10)
+		a, _ := ui.FromBig(amount)
+		if got := scale(a, test.factor).ToBig().String(); got != test.want {
+			t.Errorf("scale(%s, %v) = %s, want %s", test.amount, test.factor, got, test.want)
+		}
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	pool := testPool(t)
+	if err := pool.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	// Swaps back and forth inside the interval of the strategy
+	transactions := ent.Slice{}
+	for timestamp := 0; timestamp < 24*3600; timestamp += 600 {
+		trans := ent.Transaction{Type: "Swap", Timestamp: timestamp, Amount0: new(ui.Int), Amount1: new(ui.Int)}
+		if timestamp%1200 == 0 {
+			trans.Amount0 = ui.NewInt(100_000_000)
+		} else {
+			trans.Amount1 = ui.NewInt(30_000_000_000_000_000)
+		}
+		transactions = append(transactions, trans)
+	}
+
+	cfg := &config.Config{
+		Strategy: config.Strategy{Name: "range_exit", Parameters: sweep.Point{"interval_width": 1000}},
+		Capacity: &sweep.Dimension{From: 1, To: 10000, Step: 100},
+	}
+	space, err := newSpace(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := cfg.Sampling.Points(space)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// About 1 USD in each token like the configs
+	startAmount0, startAmount1 := ui.NewInt(1_000_000), ui.NewInt(366_874_042_000_000)
+	s := sweep.Sweep{
+		Points:    points,
+		Scheduler: executor.NewScheduler(nil),
+		Build: func(p sweep.Point) (*executor.Execution, error) {
+			amount0, amount1 := scale(startAmount0, p[sweep.Notional]), scale(startAmount1, p[sweep.Notional])
+			strategy, err := strat.New(cfg.Strategy.Name, amount0, amount1, pool.Clone(), strat.Params{"interval_width": p["interval_width"]})
+			if err != nil {
+				return nil, err
+			}
+			execution := executor.CreateExecution(strategy, 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+			execution.Liquidation = true
+			return execution, nil
+		},
+	}
+	results, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || failedRuns(results) > 0 {
+		t.Fatalf("results %+v", results)
+	}
+	byNotional := make(map[float64]int)
+	for i, r := range results {
+		byNotional[r.Parameters[sweep.Notional]] = i
+	}
+	startValue := func(notional float64) float64 {
+		value, _ := strconv.ParseFloat(results[byNotional[notional]].StartAmount, 64)
+		return value
+	}
+	// The start value in USD grows with the notional, both halves are worth about 1 USD
+	if value := startValue(1); value < 1_500_000 || value > 2_500_000 {
+		t.Errorf("start value %v at notional 1", value)
+	}
+	if ratio := startValue(100) / startValue(1); math.Abs(ratio-100) > 1 {
+		t.Errorf("start value of notional 100 is %v times the one of notional 1", ratio)
+	}
+	// A larger position takes more of the fees and costs more to sell
+	for _, sizes := range [][2]float64{{1, 100}, {100, 10000}} {
+		small, large := results[byNotional[sizes[0]]], results[byNotional[sizes[1]]]
+		if large.FeeShare <= small.FeeShare {
+			t.Errorf("fee share %v at notional %v, %v at %v", large.FeeShare, sizes[1], small.FeeShare, sizes[0])
+		}
+		if large.LiquidationCost <= small.LiquidationCost {
+			t.Errorf("liquidation cost %v at notional %v, %v at %v", large.LiquidationCost, sizes[1], small.LiquidationCost, sizes[0])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	ent "uniswap-simulator/lib/transaction"
+)
+
+// convert writes a JSON transaction file as a binary event file
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	inPtr := flags.String("in", "", "JSON array or JSON lines file of transactions")
+	outPtr := flags.String("out", "", "binary event file to write")
+	check(flags.Parse(args))
+	if *inPtr == "" || *outPtr == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	it, err := ent.File(*inPtr).Open()
+	check(err)
+	defer it.Close()
+	file, err := os.Create(*outPtr)
+	check(err)
+	encoder, err := ent.NewBinaryEncoder(file)
+	check(err)
+
+	count := 0
+	for {
+		trans, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		check(err)
+		check(encoder.Encode(trans))
+		count++
+	}
+	check(encoder.Flush())
+	check(file.Close())
+	fmt.Printf("Converted %d transactions from %s to %s\n", count, *inPtr, *outPtr)
+}
@@ -1,39 +1,31 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"math/big"
-	"os"
 	"path"
-	"strconv"
 	"testing"
 	cons "uniswap-simulator/lib/constants"
-	ppool "uniswap-simulator/lib/pool"
+	strat "uniswap-simulator/lib/strategy"
 	ent "uniswap-simulator/lib/transaction"
 	ui "uniswap-simulator/uint256"
 )
 
 func Test_Fee(t *testing.T) {
 	transactions := getTransactionsTest()
-	token0 := "USDC"
-	token1 := "WETH"
-	fee := 500
-	sqrtX96big, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
-	sqrtX96, _ := ui.FromBig(sqrtX96big)
-
-	pool := ppool.NewPool(token0, token1, fee, sqrtX96)
+	pool := testPool(t)
 
 	amountBig, _ := new(big.Int).SetString("93924580278", 10)
 	amount, _ := ui.FromBig(amountBig)
-	pool.MintStrategy(190880, 198880, amount)
+	if _, _, err := pool.Mint(strat.DefaultOwner, 190880, 198880, amount); err != nil {
+		t.Fatal(err)
+	}
 	for _, trans := range transactions {
 		switch trans.Type {
 		case "Mint":
-			pool.Mint(trans.TickLower, trans.TickUpper, trans.Amount)
+			pool.AddLiquidity(trans.TickLower, trans.TickUpper, trans.Amount)
 		case "Burn":
-			pool.Burn(trans.TickLower, trans.TickUpper, trans.Amount)
+			pool.RemoveLiquidity(trans.TickLower, trans.TickUpper, trans.Amount)
 		case "Swap":
 			if trans.Amount0.Sign() > 0 {
 				if trans.UseX96 {
@@ -52,8 +44,13 @@ func Test_Fee(t *testing.T) {
 			pool.Flash(trans.Amount0, trans.Amount1)
 		}
 	}
-	pool.BurnStrategy(190880, 198880, amount)
-	amount0, amount1 := pool.CollectStrategy(190880, 198880)
+	if _, _, err := pool.Burn(strat.DefaultOwner, 190880, 198880, amount); err != nil {
+		t.Fatal(err)
+	}
+	amount0, amount1, err := pool.Collect(strat.DefaultOwner, 190880, 198880)
+	if err != nil {
+		t.Fatal(err)
+	}
 	amount0Str := fmt.Sprintf("%d", amount0)
 	amount1Str := fmt.Sprintf("%d", amount1)
 	liquidityStr := fmt.Sprintf("%d", pool.Liquidity)
@@ -75,32 +72,5 @@ func Test_Fee(t *testing.T) {
 }
 
 func getTransactionsTest() []ent.Transaction {
-	filename := "trans.json"
-	filepath := path.Join("data", filename)
-	file, err := os.Open(filepath)
-	check(err)
-	value, err := ioutil.ReadAll(file)
-	check(err)
-	var transactionsInput []ent.TransactionInput
-	err = json.Unmarshal([]byte(value), &transactionsInput)
-	check(err)
-	var transactions []ent.Transaction
-	for _, transIn := range transactionsInput {
-		useX96, _ := strconv.ParseBool(transIn.UseX96)
-		trans := ent.Transaction{
-			transIn.Type,
-			stringToUint256(transIn.Amount),
-			stringToUint256(transIn.Amount0),
-			stringToUint256(transIn.Amount1),
-			transIn.ID,
-			stringToUint256(transIn.SqrtPriceX96),
-			transIn.Tick,
-			transIn.TickLower,
-			transIn.TickUpper,
-			transIn.Timestamp,
-			useX96,
-		}
-		transactions = append(transactions, trans)
-	}
-	return transactions
+	return getTransactions(path.Join("data", "trans.json"))
 }
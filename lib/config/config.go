@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"uniswap-simulator/lib/gas"
+	"uniswap-simulator/lib/result"
+	"uniswap-simulator/lib/sweep"
+	ui "uniswap-simulator/uint256"
+)
+
+// Config describes a backtest run.
+// It is loaded from a JSON file so experiments can be versioned next to their results.
+type Config struct {
+	Pool     Pool   `json:"pool"`
+	DataFile string `json:"data_file"`
+	// Pools replaces Pool and DataFile for strategies over several pools of the same pair, e.g. the fee tiers.
+	// Every pool has its own data file, the histories are merged by timestamp. The first pool values the amounts.
+	Pools []Pool `json:"pools"`
+	// Stream reads the data file on every run instead of holding it in memory
+	Stream       bool   `json:"stream"`
+	StartAmount0 string `json:"start_amount0"`
+	StartAmount1 string `json:"start_amount1"`
+	// StartAmount is only reported in the result file
+	StartAmount string `json:"start_amount"`
+	// StartOffset in seconds after the first transaction. Ignored if StartTime is set.
+	StartOffset int `json:"start_offset"`
+	StartTime   int `json:"start_time"`
+	EndTime     int `json:"end_time"`
+	// Intervals in seconds
+	UpdateInterval       int      `json:"update_interval"`
+	SnapshotInterval     int      `json:"snapshot_interval"`
+	PriceHistoryInterval int      `json:"price_history_interval"`
+	HistoryWindow        int      `json:"history_window"`
+	Strategy             Strategy `json:"strategy"`
+	// Shared replays these strategies in one instance of the pools, each as its own owner with the start amounts,
+	// instead of sweeping Strategy over Grid
+	Shared   []Strategy                 `json:"shared"`
+	Grid     map[string]sweep.Dimension `json:"grid"`
+	Sampling sweep.Sampling             `json:"sampling"`
+	Output   string                     `json:"output"`
+	// Format of the output file, see result.Formats
+	Format string `json:"format"`
+	// Gas charges the strategy actions if set. The cost is paid in token1, which must be WETH.
+	Gas *gas.Config `json:"gas"`
+	// Liquidation also values the runs at what selling token1 in the pool would return, not only at the spot price
+	Liquidation bool `json:"liquidation"`
+	// Capacity adds the geometric range of notional sizes, multiples of the start amounts, to the grid,
+	// e.g. {"from": 1, "to": 100000000, "step": 10}, and turns on Liquidation
+	Capacity *sweep.Dimension `json:"capacity"`
+	// SnapshotDir receives the snapshot series of the runs if set
+	SnapshotDir string `json:"snapshot_dir"`
+	// SnapshotTop only records the series of the runs with the highest return, by running them again after the sweep
+	SnapshotTop int `json:"snapshot_top"`
+}
+
+type Pool struct {
+	Token0       string `json:"token0"`
+	Token1       string `json:"token1"`
+	Fee          int    `json:"fee"`
+	SqrtPriceX96 string `json:"sqrt_price_x96"`
+	// TickSpacing is required for fees without a default tick spacing in constants.TickSpaces
+	TickSpacing int `json:"tick_spacing"`
+	// FeeProtocol0 and FeeProtocol1 turn on the protocol fee of 1/x of the swap fees in token0 and token1, 4 to 10
+	FeeProtocol0 int `json:"fee_protocol0"`
+	FeeProtocol1 int `json:"fee_protocol1"`
+	// DataFile is only set in Pools
+	DataFile string `json:"data_file"`
+}
+
+func (p Pool) validate() error {
+	if p.Token0 == "" || p.Token1 == "" {
+		return fmt.Errorf("pool tokens must be set")
+	}
+	if _, ok := ParseUint256(p.SqrtPriceX96); !ok {
+		return fmt.Errorf("invalid pool sqrt_price_x96 %q", p.SqrtPriceX96)
+	}
+	if p.DataFile == "" {
+		return fmt.Errorf("data_file must be set")
+	}
+	for _, feeProtocol := range []int{p.FeeProtocol0, p.FeeProtocol1} {
+		if feeProtocol != 0 && (feeProtocol < 4 || feeProtocol > 10) {
+			return fmt.Errorf("fee_protocol0 and fee_protocol1 must be 0 or in [4, 10]")
+		}
+	}
+	return nil
+}
+
+// PoolList returns Pools, or Pool with the DataFile for a single pool
+func (c *Config) PoolList() []Pool {
+	if len(c.Pools) > 0 {
+		return c.Pools
+	}
+	pool := c.Pool
+	pool.DataFile = c.DataFile
+	return []Pool{pool}
+}
+
+type Strategy struct {
+	Name       string             `json:"name"`
+	Parameters map[string]float64 `json:"parameters"`
+}
+
+func Load(filepath string) (*Config, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c := &Config{
+		EndTime:          math.MaxInt64,
+		SnapshotInterval: 60 * 60,
+	}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("config %s: %w", filepath, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", filepath, err)
+	}
+	return c, nil
+}
+
+func (c *Config) Validate() error {
+	if len(c.Pools) > 0 && (c.Pool != Pool{} || c.DataFile != "") {
+		return fmt.Errorf("pools replaces pool and data_file")
+	}
+	if c.Pool.DataFile != "" {
+		return fmt.Errorf("data_file of pool must be set in the config")
+	}
+	if len(c.Pools) == 0 {
+		if err := c.PoolList()[0].validate(); err != nil {
+			return err
+		}
+	}
+	for i, pool := range c.Pools {
+		if err := pool.validate(); err != nil {
+			return fmt.Errorf("pools %d: %w", i, err)
+		}
+		if pool.Token0 != c.Pools[0].Token0 || pool.Token1 != c.Pools[0].Token1 {
+			return fmt.Errorf("pools %d: all pools must have the same tokens", i)
+		}
+	}
+	if token1 := c.PoolList()[0].Token1; c.Gas != nil && token1 != "WETH" {
+		return fmt.Errorf("gas is paid in token1, which must be WETH, not %s", token1)
+	}
+	if _, ok := ParseUint256(c.StartAmount0); !ok {
+		return fmt.Errorf("invalid start_amount0 %q", c.StartAmount0)
+	}
+	if _, ok := ParseUint256(c.StartAmount1); !ok {
+		return fmt.Errorf("invalid start_amount1 %q", c.StartAmount1)
+	}
+	if len(c.Shared) > 0 {
+		if c.Strategy.Name != "" || len(c.Grid) > 0 {
+			return fmt.Errorf("shared replaces strategy and grid")
+		}
+		if c.SnapshotDir != "" {
+			return fmt.Errorf("snapshot_dir is not supported with shared")
+		}
+		for i, strategy := range c.Shared {
+			if strategy.Name == "" {
+				return fmt.Errorf("shared %d: strategy name must be set", i)
+			}
+		}
+	} else if c.Strategy.Name == "" {
+		return fmt.Errorf("strategy name must be set")
+	}
+	if c.UpdateInterval <= 0 || c.SnapshotInterval <= 0 || c.PriceHistoryInterval <= 0 {
+		return fmt.Errorf("update_interval, snapshot_interval and price_history_interval must be positive")
+	}
+	if c.SnapshotTop < 0 {
+		return fmt.Errorf("snapshot_top must not be negative")
+	}
+	if _, err := result.NewWriter(c.Format); err != nil {
+		return err
+	}
+	for name, dimension := range c.Grid {
+		if err := dimension.Validate(); err != nil {
+			return fmt.Errorf("grid %s: %w", name, err)
+		}
+	}
+	if c.Capacity != nil {
+		if len(c.Shared) > 0 {
+			return fmt.Errorf("capacity is not supported with shared")
+		}
+		if _, ok := c.Grid[sweep.Notional]; ok {
+			return fmt.Errorf("capacity replaces the %s of the grid", sweep.Notional)
+		}
+		capacity := *c.Capacity
+		capacity.Geometric = true
+		if err := capacity.Validate(); err != nil {
+			return fmt.Errorf("capacity: %w", err)
+		}
+	}
+	return nil
+}
+
+func ParseUint256(amount string) (*ui.Int, bool) {
+	bigint, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, false
+	}
+	amountUint256, overflow := ui.FromBig(bigint)
+	return amountUint256, !overflow
+}
@@ -19,6 +19,7 @@ var (
 )
 
 var TickSpaces = map[int]int{
+	100:   1,
 	500:   10,
 	3000:  60,
 	10000: 200,
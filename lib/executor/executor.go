@@ -1,14 +1,55 @@
 package executor
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"math/big"
 	cons "uniswap-simulator/lib/constants"
 	"uniswap-simulator/lib/fullmath"
+	"uniswap-simulator/lib/gas"
+	ppool "uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/result"
 	strat "uniswap-simulator/lib/strategy"
 	ent "uniswap-simulator/lib/transaction"
 	ui "uniswap-simulator/uint256"
 )
 
+// ErrUnknownPool is returned for a transaction of a pool the strategy does not have
+var ErrUnknownPool = errors.New("transaction of an unknown pool")
+
+// TransactionError is returned by Run when a transaction of the history cannot be replayed
+type TransactionError struct {
+	ID        string
+	Type      string
+	Timestamp int
+	Err       error
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("replaying %s %s at %d: %v", e.Type, e.ID, e.Timestamp, e.Err)
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// StrategyError is returned by Run when a call of the strategy fails
+type StrategyError struct {
+	Call      string
+	Timestamp int
+	Err       error
+}
+
+func (e *StrategyError) Error() string {
+	return fmt.Sprintf("strategy %s at %d: %v", e.Call, e.Timestamp, e.Err)
+}
+
+func (e *StrategyError) Unwrap() error {
+	return e.Err
+}
+
 type Execution struct {
 	Strategy                strat.Strategy
 	StartTime               int
@@ -17,14 +58,59 @@ type Execution struct {
 	SnapShotInterval        int
 	PricesSnapshotsInterval int
 	AmountUSDSnapshots      []*ui.Int
-	Transactions            []ent.Transaction
+	// Liquidation also values every snapshot at what selling amount1 in the pool would return,
+	// see liquidationValue, in LiquidationUSDSnapshots
+	Liquidation             bool
+	LiquidationUSDSnapshots []*ui.Int
+	// SqrtPriceSnapshots are the pool prices of AmountUSDSnapshots
+	SqrtPriceSnapshots []*ui.Int
+	// StartAmounts are the amounts of the strategy at Init, Fees the fees it earned in the run
+	StartAmount0 *ui.Int
+	StartAmount1 *ui.Int
+	Fees0        *ui.Int
+	Fees1        *ui.Int
+	// PoolFees are the fees paid to all liquidity of the pools from Init to the end
+	PoolFees0 *ui.Int
+	PoolFees1 *ui.Int
+	// Gas charges the strategy actions if set. The strategy pays the cost in token1 from its idle amounts,
+	// what they cannot cover is owed and deducted from the snapshots until it is paid.
+	// GasCost is the total cost of the run.
+	Gas            *gas.Model
+	GasUnits       int
+	GasCost        *ui.Int
+	gasOwed        *ui.Int
+	startTimestamp int
+	endTimestamp   int
+	// Snapshots are only recorded with RecordSnapshots, next to every entry of AmountUSDSnapshots
+	RecordSnapshots bool
+	Snapshots       []result.Snapshot
+	// Fills are the range orders converted in the run, for strategies with RangeOrders
+	Fills        []strat.Fill
+	Transactions ent.Source
+	// Divergences counts the replayed swaps that ended at another price or tick than recorded,
+	// usually because the liquidity of the strategy was in range
+	Divergences     int
+	FirstDivergence *Divergence
+	// pools are replayed by the Pool index of the transactions, the strategy pool or its MultiPool pools
+	pools []*ppool.Pool
+	// State of the replay
+	hooks             strat.Hooks
+	hasHooks          bool
+	orders            strat.RangeOrders
+	hasOrders         bool
+	started           bool
+	lastTimestamp     int
+	nextUpdate        int
+	nextSnapshot      int
+	nextPriceSnapshot int
+	// lpFees of the pools at Init
+	lpFees0 *ui.Int
+	lpFees1 *ui.Int
 }
 
-func CreateExecution(strategy strat.Strategy, startTime, endTime, updateInterval, snapShotInterval, priceSnapshotInterval int, transactions []ent.Transaction) *Execution {
+func CreateExecution(strategy strat.Strategy, startTime, endTime, updateInterval, snapShotInterval, priceSnapshotInterval int, transactions ent.Source) *Execution {
 
-	maxTime := transactions[len(transactions)-1].Timestamp
-	length := (maxTime - startTime) / updateInterval
-	snapshots := make([]*ui.Int, 0, length)
+	snapshots := make([]*ui.Int, 0)
 
 	return &Execution{
 		Strategy:                strategy,
@@ -35,96 +121,493 @@ func CreateExecution(strategy strat.Strategy, startTime, endTime, updateInterval
 		PricesSnapshotsInterval: priceSnapshotInterval,
 		Transactions:            transactions,
 		AmountUSDSnapshots:      snapshots,
+		GasCost:                 ui.NewInt(0),
+		gasOwed:                 ui.NewInt(0),
 	}
 
 }
 
-func (e *Execution) Run() {
-	//
-	strategy := e.Strategy
-	transactions := e.Transactions
-	pool := strategy.GetPool()
+// Run replays the transactions against the strategy's pools.
+// Transactions are streamed from the Source, so the history is never held in memory by the executor.
+func (e *Execution) Run() error {
+	transactions, err := e.Transactions.Open()
+	if err != nil {
+		return err
+	}
+	defer transactions.Close()
+	e.prepare()
+	for {
+		trans, err := transactions.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if trans.Timestamp > e.EndTime {
+			break
+		}
+		if err := e.before(trans); err != nil {
+			return err
+		}
+		pool, err := e.pool(trans)
+		if err != nil {
+			return err
+		}
+		tickBefore := pool.TickCurrent
+		if err := replay(pool, trans); err != nil {
+			return &TransactionError{trans.ID, trans.Type, trans.Timestamp, err}
+		}
+		e.check(pool, trans)
+		if err := e.after(trans, tickBefore); err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
 
-	started := false
-	nextUpdate := math.MaxInt64
-	nextSnapshot := math.MaxInt64
+// prepare resets the state of the replay
+func (e *Execution) prepare() {
+	e.pools = []*ppool.Pool{e.Strategy.GetPool()}
+	if multiPool, ok := e.Strategy.(strat.MultiPool); ok {
+		e.pools = multiPool.GetPools()
+	}
+	e.hooks, e.hasHooks = e.Strategy.(strat.Hooks)
+	e.orders, e.hasOrders = e.Strategy.(strat.RangeOrders)
+	e.started = false
+	e.Divergences = 0
+	e.FirstDivergence = nil
+	e.lastTimestamp = 0
+	e.nextUpdate = math.MaxInt64
+	e.nextSnapshot = math.MaxInt64
 	// We need some Snapshots for Init(), the easiest way is to get Snapshots from the first transaction
-	nextPriceSnapshot := 0
-	//limitRebalance := false
-	for _, trans := range transactions {
+	e.nextPriceSnapshot = 0
+}
 
-		if trans.Timestamp > e.EndTime {
-			break
+// before starts the strategy, takes the snapshots and rebalances that are due before trans is replayed
+func (e *Execution) before(trans ent.Transaction) error {
+	strategy := e.Strategy
+	e.lastTimestamp = trans.Timestamp
+	// The block of the transaction, for the oracle observations of the strategy and the replay
+	for _, p := range e.pools {
+		p.Timestamp = trans.Timestamp
+	}
+
+	// Start Strategy
+	if !e.started && trans.Timestamp >= e.StartTime {
+
+		actions := e.actions()
+		amount0, amount1, err := strategy.Init()
+		if err != nil {
+			return &StrategyError{"Init", trans.Timestamp, err}
+		}
+		e.StartAmount0, e.StartAmount1 = amount0.Clone(), amount1.Clone()
+		e.startTimestamp = trans.Timestamp
+		if err := e.snapshot(trans.Timestamp, amount0, amount1); err != nil {
+			return err
+		}
+		// The start amount is before gas, so the gas of Init is charged after its snapshot
+		e.charge(trans.Timestamp, e.actions().Sub(actions))
+		e.lpFees0, e.lpFees1 = e.lpFees()
+
+		e.nextUpdate = trans.Timestamp + e.UpdateInterval
+		e.nextSnapshot = trans.Timestamp + e.SnapShotInterval
+		e.started = true
+	}
+	//Price Snapshot
+	if trans.Timestamp > e.nextPriceSnapshot {
+		if err := e.Strategy.MakeSnapshot(); err != nil {
+			return &StrategyError{"MakeSnapshot", trans.Timestamp, err}
+		}
+		e.nextPriceSnapshot += e.PricesSnapshotsInterval
+	}
+
+	// Snapshot
+	if trans.Timestamp >= e.nextSnapshot {
+		amount0, amount1 := strategy.GetAmounts()
+		if err := e.snapshot(trans.Timestamp, amount0, amount1); err != nil {
+			return err
+		}
+		e.nextSnapshot += e.SnapShotInterval
+	}
+
+	// Rebalance
+	if trans.Timestamp >= e.nextUpdate {
+		actions := e.actions()
+		var err error
+		if e.hasHooks {
+			err = e.hooks.OnTimer(trans.Timestamp)
+		} else {
+			_, _, err = strategy.Rebalance()
+		}
+		if err != nil {
+			return &StrategyError{"Rebalance", trans.Timestamp, err}
+		}
+		e.charge(trans.Timestamp, e.actions().Sub(actions))
+		e.nextUpdate += e.UpdateInterval
+	}
+	return nil
+}
+
+// pool returns the pool trans is replayed against
+func (e *Execution) pool(trans ent.Transaction) (*ppool.Pool, error) {
+	if trans.Pool < 0 || trans.Pool >= len(e.pools) {
+		return nil, &TransactionError{trans.ID, trans.Type, trans.Timestamp, fmt.Errorf("%w %d of %d", ErrUnknownPool, trans.Pool, len(e.pools))}
+	}
+	return e.pools[trans.Pool], nil
+}
+
+// after fills the range orders and calls the hooks of the strategy once trans was replayed
+func (e *Execution) after(trans ent.Transaction, tickBefore int) error {
+	if !e.started {
+		return nil
+	}
+	if e.hasOrders {
+		actions := e.actions()
+		if err := e.fillOrders(e.orders, trans, tickBefore); err != nil {
+			return &StrategyError{"OnFill", trans.Timestamp, err}
+		}
+		e.charge(trans.Timestamp, e.actions().Sub(actions))
+	}
+
+	if e.hasHooks {
+		actions := e.actions()
+		if err := e.callHooks(e.hooks, trans, tickBefore); err != nil {
+			return &StrategyError{"hooks", trans.Timestamp, err}
+		}
+		e.charge(trans.Timestamp, e.actions().Sub(actions))
+	}
+	return nil
+}
+
+// finish withdraws the strategy at the last replayed transaction
+func (e *Execution) finish() error {
+	strategy := e.Strategy
+	actions := e.actions()
+	amount0, amount1, err := strategy.BurnAll()
+	if err != nil {
+		return &StrategyError{"BurnAll", e.lastTimestamp, err}
+	}
+	// The withdrawn amounts are before the gas of the withdrawal, the last snapshot deducts it
+	e.gasOwed.Add(e.gasOwed, e.gasCost(e.lastTimestamp, e.actions().Sub(actions)))
+	e.Fees0, e.Fees1 = new(ui.Int), new(ui.Int)
+	for _, p := range e.pools {
+		fees0, fees1 := p.FeesEarned(strategy.GetOwner())
+		e.Fees0.Add(e.Fees0, fees0)
+		e.Fees1.Add(e.Fees1, fees1)
+	}
+	if e.started {
+		lpFees0, lpFees1 := e.lpFees()
+		e.PoolFees0 = lpFees0.Sub(lpFees0, e.lpFees0)
+		e.PoolFees1 = lpFees1.Sub(lpFees1, e.lpFees1)
+	}
+	e.endTimestamp = e.lastTimestamp
+	return e.snapshot(e.lastTimestamp, amount0, amount1)
+}
+
+// lpFees sums the fees paid to the liquidity of all pools
+func (e *Execution) lpFees() (fees0, fees1 *ui.Int) {
+	fees0, fees1 = new(ui.Int), new(ui.Int)
+	for _, p := range e.pools {
+		fees0.Add(fees0, p.LPFees0)
+		fees1.Add(fees1, p.LPFees1)
+	}
+	return
+}
+
+// replay applies a transaction of the history to the pool
+func replay(pool *ppool.Pool, trans ent.Transaction) error {
+	switch trans.Type {
+	case "Mint":
+		return pool.AddLiquidity(trans.TickLower, trans.TickUpper, trans.Amount)
+	case "Burn":
+		return pool.RemoveLiquidity(trans.TickLower, trans.TickUpper, trans.Amount)
+	case "Swap":
+		_, _, err := Swap(pool, trans)
+		// The liquidity of the strategy can move the price past the limit, the swap would have reverted
+		if trans.UseX96 && errors.Is(err, ppool.ErrPriceLimit) {
+			return nil
+		}
+		return err
+	case "Flash":
+		return pool.Flash(trans.Amount0, trans.Amount1)
+	}
+	return nil
+}
+
+// Swap replays a swap of the history like it was sent: for the exact amount in or out,
+// with the recorded price as limit if UseX96. It returns the amounts of the pool like the Swap event.
+func Swap(pool *ppool.Pool, trans ent.Transaction) (amount0, amount1 *ui.Int, err error) {
+	sqrtPriceLimitX96 := cons.Zero
+	if trans.UseX96 {
+		sqrtPriceLimitX96 = trans.SqrtPriceX96
+	}
+	if trans.Amount0.Sign() > 0 {
+		if trans.ExactOutput {
+			return pool.ExactOutputSwap(new(ui.Int).Neg(trans.Amount1), pool.Token1, sqrtPriceLimitX96)
+		}
+		return pool.ExactInputSwap(trans.Amount0, pool.Token0, sqrtPriceLimitX96)
+	}
+	if trans.Amount1.Sign() > 0 {
+		if trans.ExactOutput {
+			return pool.ExactOutputSwap(new(ui.Int).Neg(trans.Amount0), pool.Token0, sqrtPriceLimitX96)
+		}
+		return pool.ExactInputSwap(trans.Amount1, pool.Token1, sqrtPriceLimitX96)
+	}
+	return new(ui.Int), new(ui.Int), nil
+}
+
+// Divergence is a replayed swap that left the pool at another price than the recorded one
+type Divergence struct {
+	ID                   string
+	Timestamp            int
+	SqrtPriceX96         *ui.Int
+	RecordedSqrtPriceX96 *ui.Int
+	Tick                 int
+	RecordedTick         int
+}
+
+// diverged compares the pool after a swap with the price and tick of the event, nil if they match or were not recorded
+func diverged(pool *ppool.Pool, trans ent.Transaction) *Divergence {
+	if trans.Type != "Swap" || trans.SqrtPriceX96 == nil || trans.SqrtPriceX96.IsZero() {
+		return nil
+	}
+	if pool.SqrtRatioX96.Eq(trans.SqrtPriceX96) && pool.TickCurrent == trans.Tick {
+		return nil
+	}
+	return &Divergence{
+		ID:                   trans.ID,
+		Timestamp:            trans.Timestamp,
+		SqrtPriceX96:         pool.SqrtRatioX96.Clone(),
+		RecordedSqrtPriceX96: trans.SqrtPriceX96.Clone(),
+		Tick:                 pool.TickCurrent,
+		RecordedTick:         trans.Tick,
+	}
+}
+
+// check counts a divergence of the pool from the event trans
+func (e *Execution) check(pool *ppool.Pool, trans ent.Transaction) {
+	divergence := diverged(pool, trans)
+	if divergence == nil {
+		return
+	}
+	if e.Divergences == 0 {
+		e.FirstDivergence = divergence
+	}
+	e.Divergences++
+}
+
+// Attribution returns the amounts needed to split the return of the run into fees and price exposure
+func (e *Execution) Attribution() result.Attribution {
+	return result.Attribution{
+		StartAmount0:   e.StartAmount0,
+		StartAmount1:   e.StartAmount1,
+		Fees0:          e.Fees0,
+		Fees1:          e.Fees1,
+		PoolFees0:      e.PoolFees0,
+		PoolFees1:      e.PoolFees1,
+		SqrtPricesX96:  e.SqrtPriceSnapshots,
+		LiquidationUSD: e.LiquidationUSDSnapshots,
+		GasCost1:       e.GasCost,
+		Duration:       e.endTimestamp - e.startTimestamp,
+	}
+}
+
+// actions sums the actions in all pools
+func (e *Execution) actions() ppool.ActionCounts {
+	var actions ppool.ActionCounts
+	for _, p := range e.pools {
+		actions.Mint += p.Actions.Mint
+		actions.Burn += p.Actions.Burn
+		actions.Collect += p.Actions.Collect
+		actions.Swap += p.Actions.Swap
+	}
+	return actions
+}
+
+// fillOrders calls OnFill for the range orders the last transaction moved the price across
+func (e *Execution) fillOrders(orders strat.RangeOrders, trans ent.Transaction, tickBefore int) error {
+	pool := e.pools[trans.Pool]
+	// OnFill may place new orders, which are not filled by this transaction
+	open := append([]strat.RangeOrder(nil), orders.GetRangeOrders()...)
+	for _, order := range open {
+		if order.Pool() != trans.Pool || order.Filled(tickBefore) || !order.Filled(pool.TickCurrent) {
+			continue
 		}
+		fill := strat.Fill{
+			Order:          order,
+			Timestamp:      trans.Timestamp,
+			ExecutionPrice: order.ExecutionPrice(),
+			MidPrice:       strat.SqrtPriceToFloat(pool.SqrtRatioX96),
+		}
+		e.Fills = append(e.Fills, fill)
+		if err := orders.OnFill(fill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// Start Strategy
-		if !started && trans.Timestamp >= e.StartTime {
-
-			amount0, amount1 := strategy.Init()
-			// Not Precise
-			x96 := strategy.GetPool().SqrtRatioX96
-			priceSquareX192 := new(ui.Int).Mul(x96, x96)
-			amount1to0 := fullmath.MulDiv(amount1, cons.Q192, priceSquareX192)
-			amountUSD := new(ui.Int).Add(amount1to0, amount0)
-			e.AmountUSDSnapshots = append(e.AmountUSDSnapshots, amountUSD)
-
-			nextUpdate = trans.Timestamp + e.UpdateInterval
-			nextSnapshot = trans.Timestamp + e.SnapShotInterval
-			started = true
-		}
-		//var condition bool
-		//if strategy.GetDirections() {
-		//	condition = strategy.GetPool().TickCurrent > strategy.GetCurrentLimitTick()
-		//} else {
-		//	condition = strategy.GetPool().TickCurrent < strategy.GetCurrentLimitTick()
-		//}
-		//if condition && limitRebalance {
-		//	strategy.Rebalance()
-		//	limitRebalance = false
-		//}
-		//Price Snapshot
-		if trans.Timestamp > nextPriceSnapshot {
-			e.Strategy.MakeSnapshot()
-			nextPriceSnapshot += e.PricesSnapshotsInterval
-		}
-
-		// Snapshot
-		if trans.Timestamp >= nextSnapshot {
-			amount0, amount1 := strategy.GetAmounts()
-			x96 := strategy.GetPool().SqrtRatioX96
-			priceSquareX192 := new(ui.Int).Mul(x96, x96)
-			amount1to0 := fullmath.MulDiv(amount1, cons.Q192, priceSquareX192)
-			amountUSD := new(ui.Int).Add(amount1to0, amount0)
-			e.AmountUSDSnapshots = append(e.AmountUSDSnapshots, amountUSD)
-			nextSnapshot += e.SnapShotInterval
-		}
-
-		// Rebalance
-		if trans.Timestamp >= nextUpdate {
-			strategy.Rebalance()
-			nextUpdate += e.UpdateInterval
-		}
-		switch trans.Type {
-		case "Mint":
-			pool.Mint(trans.TickLower, trans.TickUpper, trans.Amount)
-		case "Burn":
-			pool.Burn(trans.TickLower, trans.TickUpper, trans.Amount)
-		case "Swap":
-			if trans.Amount0.Sign() > 0 {
-				pool.ExactInputSwap(trans.Amount0, pool.Token0, cons.Zero)
-			} else if trans.Amount1.Sign() > 0 {
-				pool.ExactInputSwap(trans.Amount1, pool.Token1, cons.Zero)
+// FillPriceVsMid is the average PriceVsMid of the Fills, 0 without fills
+func (e *Execution) FillPriceVsMid() float64 {
+	if len(e.Fills) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, fill := range e.Fills {
+		sum += fill.PriceVsMid()
+	}
+	return sum / float64(len(e.Fills))
+}
+
+// callHooks notifies a strategy with Hooks of a replayed transaction.
+// Ticks are the ones of the pool of the transaction.
+// The positions leaving their range are the ones open in that pool before the hooks are called.
+func (e *Execution) callHooks(hooks strat.Hooks, trans ent.Transaction, tickBefore int) error {
+	positions := e.Strategy.GetPositions()
+	if err := hooks.OnTransaction(trans); err != nil {
+		return err
+	}
+	tickAfter := e.pools[trans.Pool].TickCurrent
+	if tickAfter == tickBefore {
+		return nil
+	}
+	if err := hooks.OnTickCross(tickBefore, tickAfter); err != nil {
+		return err
+	}
+	for _, position := range positions {
+		if position.Pool() == trans.Pool && position.InRange(tickBefore) && !position.InRange(tickAfter) {
+			if err := hooks.OnPriceOutOfRange(position); err != nil {
+				return err
 			}
-		case "Flash":
-			pool.Flash(trans.Amount0, trans.Amount1)
 		}
 	}
+	return nil
+}
+
+// charge lets the strategy pay the gas of its actions at timestamp, together with the gas it still owes
+func (e *Execution) charge(timestamp int, actions ppool.ActionCounts) {
+	cost := e.gasCost(timestamp, actions)
+	if cost.IsZero() {
+		return
+	}
+	e.gasOwed = e.Strategy.PayGas(new(ui.Int).Add(e.gasOwed, cost))
+}
+
+// gasCost adds the gas of the strategy actions at timestamp to the totals and returns its cost
+func (e *Execution) gasCost(timestamp int, actions ppool.ActionCounts) *ui.Int {
+	if e.Gas == nil {
+		return new(ui.Int)
+	}
+	units := e.Gas.Units.Total(actions.Mint, actions.Burn, actions.Collect, actions.Swap)
+	cost := e.Gas.Cost(timestamp, units)
+	e.GasUnits += units
+	e.GasCost.Add(e.GasCost, cost)
+	return cost
+}
+
+// payGas deducts the gas the strategy owes from amount1. If amount1 is not enough the rest is deducted from amount0 at the spot price.
+func (e *Execution) payGas(amount0, amount1, priceSquareX192 *ui.Int) (*ui.Int, *ui.Int) {
+	if e.gasOwed.IsZero() {
+		return amount0, amount1
+	}
+	if !amount1.Lt(e.gasOwed) {
+		return amount0, new(ui.Int).Sub(amount1, e.gasOwed)
+	}
+	rest1 := new(ui.Int).Sub(e.gasOwed, amount1)
+	rest0 := fullmath.MulDiv(rest1, cons.Q192, priceSquareX192)
+	if !amount0.Lt(rest0) {
+		return new(ui.Int).Sub(amount0, rest0), ui.NewInt(0)
+	}
+	return ui.NewInt(0), ui.NewInt(0)
+}
 
-	amount0, amount1 := strategy.BurnAll()
-	x96 := strategy.GetPool().SqrtRatioX96
+// snapshot values the amounts after the owed gas in token0 at the spot price
+func (e *Execution) snapshot(timestamp int, amount0, amount1 *ui.Int) error {
+	// Not Precise
+	pool := e.Strategy.GetPool()
+	x96 := pool.SqrtRatioX96
 	priceSquareX192 := new(ui.Int).Mul(x96, x96)
+	amount0, amount1 = e.payGas(amount0, amount1, priceSquareX192)
 	amount1to0 := fullmath.MulDiv(amount1, cons.Q192, priceSquareX192)
 	amountUSD := new(ui.Int).Add(amount1to0, amount0)
 	e.AmountUSDSnapshots = append(e.AmountUSDSnapshots, amountUSD)
+	e.SqrtPriceSnapshots = append(e.SqrtPriceSnapshots, x96.Clone())
+	var liquidationUSD *ui.Int
+	if e.Liquidation {
+		var err error
+		if liquidationUSD, err = e.liquidationValue(amount0, amount1); err != nil {
+			return err
+		}
+		e.LiquidationUSDSnapshots = append(e.LiquidationUSDSnapshots, liquidationUSD)
+	}
+	if !e.RecordSnapshots {
+		return nil
+	}
+
+	positions := e.Strategy.GetPositions()
+	positionSnapshots := make([]result.PositionSnapshot, 0, len(positions))
+	for _, position := range positions {
+		positionPool := e.pools[position.Pool()]
+		fees0, fees1, err := positionPool.UncollectedFees(position.Owner(), position.TickLower(), position.TickUpper())
+		if err != nil {
+			return err
+		}
+		positionSnapshots = append(positionSnapshots, result.PositionSnapshot{
+			Pool:             position.Pool(),
+			TickLower:        position.TickLower(),
+			TickUpper:        position.TickUpper(),
+			Liquidity:        position.Liquidity().ToBig().String(),
+			InRange:          position.InRange(positionPool.TickCurrent),
+			UncollectedFees0: fees0.ToBig().String(),
+			UncollectedFees1: fees1.ToBig().String(),
+		})
+	}
+	snapshot := result.Snapshot{
+		Timestamp: timestamp,
+		Amount0:   amount0.ToBig().String(),
+		Amount1:   amount1.ToBig().String(),
+		AmountUSD: amountUSD.ToBig().String(),
+		Price:     price(x96),
+		Tick:      pool.TickCurrent,
+		Positions: positionSnapshots,
+	}
+	if liquidationUSD != nil {
+		snapshot.LiquidationUSD = liquidationUSD.ToBig().String()
+	}
+	e.Snapshots = append(e.Snapshots, snapshot)
+	return nil
+}
+
+// liquidationValue returns amount0 plus what an exact input swap of amount1 would return in the pool
+// after the positions of the strategy were withdrawn from it. The swap is quoted, so the pool is not changed.
+// Positions in the other pools of a MultiPool stay.
+func (e *Execution) liquidationValue(amount0, amount1 *ui.Int) (*ui.Int, error) {
+	value := amount0.Clone()
+	if amount1.IsZero() {
+		return value, nil
+	}
+	pool := e.Strategy.GetPool()
+	// A position of the pool holds the liquidity of all positions of the strategy with its key
+	positions := make([]ppool.PositionKey, 0)
+	seen := make(map[ppool.PositionKey]bool)
+	for _, position := range e.Strategy.GetPositions() {
+		key := ppool.PositionKey{Owner: position.Owner(), TickLower: position.TickLower(), TickUpper: position.TickUpper()}
+		if position.Pool() == 0 && !seen[key] {
+			seen[key] = true
+			positions = append(positions, key)
+		}
+	}
+	out0, _, _, err := pool.QuoteExactInputSwap(amount1, pool.Token1, cons.Zero, positions...)
+	if err != nil {
+		return nil, err
+	}
+	return value.Sub(value, out0), nil
+}
 
+// price returns (sqrtPriceX96 / 2^96)^2 as a decimal
+func price(sqrtPriceX96 *ui.Int) string {
+	sqrtPrice := new(big.Float).SetInt(sqrtPriceX96.ToBig())
+	sqrtPrice.SetMantExp(sqrtPrice, -96)
+	return new(big.Float).Mul(sqrtPrice, sqrtPrice).Text('g', 12)
 }
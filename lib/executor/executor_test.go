@@ -0,0 +1,254 @@
+package executor
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/fullmath"
+	"uniswap-simulator/lib/gas"
+	ppool "uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/result"
+	strat "uniswap-simulator/lib/strategy"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+type recordingStrategy struct {
+	*strat.RangeExitStrategy
+	timers, crosses, exits int
+}
+
+func (s *recordingStrategy) OnTimer(int) error {
+	s.timers++
+	return nil
+}
+
+func (s *recordingStrategy) OnTickCross(int, int) error {
+	s.crosses++
+	return nil
+}
+
+func (s *recordingStrategy) OnPriceOutOfRange(position strat.Position) error {
+	s.exits++
+	return s.RangeExitStrategy.OnPriceOutOfRange(position)
+}
+
+// testPool returns a USDC/WETH pool with full range liquidity
+func testPool(t *testing.T) *ppool.Pool {
+	t.Helper()
+	sqrtPrice, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
+	sqrtPriceX96, _ := ui.FromBig(sqrtPrice)
+	pool, err := ppool.NewPool("USDC", "WETH", 500, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestHooks(t *testing.T) {
+	pool := testPool(t)
+
+	swap := func(timestamp int, amount0 int64) ent.Transaction {
+		a0, _ := ui.FromBig(big.NewInt(amount0))
+		return ent.Transaction{Type: "Swap", Timestamp: timestamp, Amount0: a0, Amount1: new(ui.Int)}
+	}
+	transactions := ent.Slice{
+		swap(0, 1_000),
+		// Moves the price down by about 2000 ticks
+		swap(10, 100_000_000_000),
+		swap(20, 1_000),
+		swap(4000, 1_000),
+	}
+	strategy := &recordingStrategy{RangeExitStrategy: strat.NewRangeExitStrategy(ui.NewInt(1_000_000), ui.NewInt(300_000_000_000_000), pool, 100)}
+	execution := CreateExecution(strategy, 0, math.MaxInt64, 3600, 3600, 60, transactions)
+	if err := execution.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if strategy.exits != 1 {
+		t.Errorf("%d exits, want 1", strategy.exits)
+	}
+	if strategy.crosses == 0 {
+		t.Error("no tick cross")
+	}
+	if strategy.timers != 1 {
+		t.Errorf("%d timers, want 1", strategy.timers)
+	}
+}
+
+func TestRangeOrderFills(t *testing.T) {
+	pool := testPool(t)
+
+	swap1 := func(timestamp int, amount1 int64) ent.Transaction {
+		a1, _ := ui.FromBig(big.NewInt(amount1))
+		return ent.Transaction{Type: "Swap", Timestamp: timestamp, Amount0: new(ui.Int), Amount1: a1}
+	}
+	transactions := ent.Slice{
+		swap1(0, 1_000),
+		// Moves the price up by more than the order range
+		swap1(10, 5_000_000_000_000_000_000),
+		swap1(20, 1_000),
+	}
+	// Only token0, so the interval leaves token0 for an order above the price
+	strategy := strat.NewLimitOrderStrategy(ui.NewInt(1_000_000_000), ui.NewInt(0), pool, 1000)
+	execution := CreateExecution(strategy, 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+	if err := execution.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(execution.Fills) != 1 {
+		t.Fatalf("%d fills, want 1", len(execution.Fills))
+	}
+	fill := execution.Fills[0]
+	if !fill.Order.ZeroForOne || fill.Timestamp != 10 {
+		t.Errorf("fill %+v", fill)
+	}
+	// The price moved past the order, so it was converted below the mid price
+	if fill.PriceVsMid() >= 0 {
+		t.Errorf("price vs mid %v", fill.PriceVsMid())
+	}
+	for _, order := range strategy.GetRangeOrders() {
+		if order.Position == fill.Order.Position {
+			t.Error("filled order was not withdrawn")
+		}
+	}
+}
+
+func TestReplaySwaps(t *testing.T) {
+	pool := testPool(t)
+
+	// Record the events of the swaps like the contract emits them
+	chain := pool.Clone()
+	transactions := ent.Slice{}
+	record := func(timestamp int, exactOutput bool, amount0, amount1 *ui.Int, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions = append(transactions, ent.Transaction{
+			Type: "Swap", Timestamp: timestamp, Amount0: amount0, Amount1: amount1,
+			SqrtPriceX96: chain.SqrtRatioX96.Clone(), Tick: chain.TickCurrent, ExactOutput: exactOutput,
+		})
+	}
+	a0, a1, err := chain.ExactInputSwap(ui.NewInt(100_000_000), chain.Token0, ui.NewInt(0))
+	record(0, false, a0, a1, err)
+	a0, a1, err = chain.ExactOutputSwap(ui.NewInt(100_000_000), chain.Token0, ui.NewInt(0))
+	record(10, true, a0, a1, err)
+	a0, a1, err = chain.ExactOutputSwap(ui.NewInt(30_000_000_000_000_000), chain.Token1, ui.NewInt(0))
+	record(20, true, a0, a1, err)
+	if out := new(ui.Int).Neg(a1); !out.Eq(ui.NewInt(30_000_000_000_000_000)) {
+		t.Fatalf("exact output swap paid out %v", out)
+	}
+	// Stopped by its price limit before the input was used up
+	limit := new(ui.Int).Add(chain.SqrtRatioX96, ui.NewInt(1<<40))
+	a0, a1, err = chain.ExactInputSwap(ui.NewInt(1_000_000_000_000_000_000), chain.Token1, limit)
+	record(30, false, a0, a1, err)
+	transactions[3].UseX96 = true
+
+	replayed := pool.Clone()
+	execution := CreateExecution(strat.NewNoProvisionStrategy(ui.NewInt(0), ui.NewInt(0), replayed), 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+	if err := execution.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if execution.Divergences != 0 {
+		t.Errorf("%d divergences, first %+v", execution.Divergences, execution.FirstDivergence)
+	}
+	if !replayed.SqrtRatioX96.Eq(chain.SqrtRatioX96) || !replayed.Liquidity.Eq(chain.Liquidity) {
+		t.Errorf("replayed price %v, recorded %v", replayed.SqrtRatioX96, chain.SqrtRatioX96)
+	}
+
+	// Liquidity in range changes how far the swaps move the price
+	strategy, err := strat.New("range_exit", ui.NewInt(1_000_000_000), ui.NewInt(300_000_000_000_000_000), pool, strat.Params{"interval_width": 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	execution = CreateExecution(strategy, 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+	if err := execution.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if execution.Divergences == 0 || execution.FirstDivergence.Timestamp != 0 {
+		t.Errorf("%d divergences, first %+v", execution.Divergences, execution.FirstDivergence)
+	}
+}
+
+func TestLiquidation(t *testing.T) {
+	pool := testPool(t)
+	transactions := ent.Slice{
+		{Type: "Swap", Timestamp: 0, Amount0: ui.NewInt(1_000_000), Amount1: new(ui.Int)},
+		{Type: "Swap", Timestamp: 3600, Amount0: new(ui.Int), Amount1: ui.NewInt(1_000_000_000_000)},
+	}
+	// The cost of selling token1 grows with the amount
+	costs := make([]float64, 2)
+	for i, scale := range []uint64{1, 1000} {
+		amount0, amount1 := ui.NewInt(1_000_000*scale), ui.NewInt(300_000_000_000_000*scale)
+		strategy, err := strat.New("range_exit", amount0, amount1, pool, strat.Params{"interval_width": 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		execution := CreateExecution(strategy, 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+		execution.Liquidation = true
+		if err := execution.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(execution.LiquidationUSDSnapshots) != len(execution.AmountUSDSnapshots) {
+			t.Fatalf("%d liquidation snapshots, %d amount snapshots", len(execution.LiquidationUSDSnapshots), len(execution.AmountUSDSnapshots))
+		}
+		for j, liquidation := range execution.LiquidationUSDSnapshots {
+			if !liquidation.Lt(execution.AmountUSDSnapshots[j]) {
+				t.Errorf("liquidation value %v, mark to market %v", liquidation, execution.AmountUSDSnapshots[j])
+			}
+		}
+		r := result.NewRunResult(execution.AmountUSDSnapshots)
+		r.Attribute(execution.Attribution())
+		if r.LiquidationReturn >= r.Return {
+			t.Errorf("liquidation return %v, return %v", r.LiquidationReturn, r.Return)
+		}
+		costs[i] = r.LiquidationCost
+	}
+	// At least the fee of the pool on the half in token1
+	if costs[0] < 0.0002 || costs[1] <= costs[0] {
+		t.Errorf("liquidation costs %v", costs)
+	}
+}
+
+func TestGas(t *testing.T) {
+	pool := testPool(t)
+	transactions := ent.Slice{
+		{Type: "Swap", Timestamp: 0, Amount0: ui.NewInt(1_000_000), Amount1: new(ui.Int)},
+		{Type: "Swap", Timestamp: 10, Amount0: new(ui.Int), Amount1: ui.NewInt(1_000_000_000_000)},
+	}
+	// 1000 USDC and 1 ETH, most of the ETH stays idle next to the position
+	run := func(model *gas.Model) (*Execution, *strat.IntervalAroundPriceStrategy) {
+		strategy := strat.NewIntervalAroundPriceStrategy(ui.NewInt(1_000_000_000), ui.NewInt(1_000_000_000_000_000_000), pool.Clone(), 1000)
+		execution := CreateExecution(strategy, 0, math.MaxInt64, 3600, 3600, 60, transactions)
+		execution.Gas = model
+		if err := execution.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return execution, strategy
+	}
+	free, freeStrategy := run(nil)
+	charged, chargedStrategy := run(gas.Constant(gas.DefaultUnits, ui.NewInt(10_000_000_000)))
+
+	// A mint, a burn and a collect
+	if charged.GasUnits != 670_000 || !charged.GasCost.Eq(ui.NewInt(6_700_000_000_000_000)) {
+		t.Fatalf("%d units for %v", charged.GasUnits, charged.GasCost)
+	}
+	// The strategy paid the mint from its idle token1, the withdrawal is deducted from the last snapshot
+	paid := new(ui.Int).Sub(freeStrategy.Amount1, chargedStrategy.Amount1)
+	if !paid.Eq(ui.NewInt(4_000_000_000_000_000)) || !freeStrategy.Amount0.Eq(chargedStrategy.Amount0) {
+		t.Errorf("paid %v and %v token0", paid, new(ui.Int).Sub(freeStrategy.Amount0, chargedStrategy.Amount0))
+	}
+	// The snapshots do not deduct the paid gas again
+	last := len(charged.AmountUSDSnapshots) - 1
+	priceSquareX192 := new(ui.Int).Mul(charged.SqrtPriceSnapshots[last], charged.SqrtPriceSnapshots[last])
+	want := new(ui.Int).Sub(free.AmountUSDSnapshots[last], fullmath.MulDiv(charged.GasCost, cons.Q192, priceSquareX192))
+	diff := new(ui.Int).Sub(want, charged.AmountUSDSnapshots[last])
+	if diff.Sign() < 0 {
+		diff.Neg(diff)
+	}
+	if diff.Gt(cons.One) {
+		t.Errorf("end value %v, want %v", charged.AmountUSDSnapshots[last], want)
+	}
+}
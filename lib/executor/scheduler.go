@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs executions on a fixed amount of workers fed from a work queue.
+// Every worker builds, runs and drops one execution at a time, so memory is bounded by Workers pool clones.
+type Scheduler struct {
+	Workers int // GOMAXPROCS if zero
+	// Progress receives a status line every ProgressInterval, nil disables progress reporting
+	Progress         io.Writer
+	ProgressInterval time.Duration
+}
+
+func NewScheduler(progress io.Writer) *Scheduler {
+	return &Scheduler{
+		Workers:          runtime.GOMAXPROCS(0),
+		Progress:         progress,
+		ProgressInterval: 10 * time.Second,
+	}
+}
+
+// Run calls task for every i in [0, n).
+// Once ctx is cancelled no new tasks are started, the running ones finish and ctx.Err() is returned.
+func (s *Scheduler) Run(ctx context.Context, n int, task func(i int)) error {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var completed int64
+	progress := newProgress(n, &completed)
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				task(i)
+				atomic.AddInt64(&completed, 1)
+			}
+		}()
+	}
+
+	stopReport := make(chan struct{})
+	reportDone := make(chan struct{})
+	go func() {
+		defer close(reportDone)
+		if s.Progress == nil || s.ProgressInterval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(s.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintln(s.Progress, progress)
+			case <-stopReport:
+				return
+			}
+		}
+	}()
+
+	var err error
+enqueue:
+	for i := 0; i < n; i++ {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break enqueue
+		}
+	}
+	close(queue)
+	wg.Wait()
+	close(stopReport)
+	<-reportDone
+	if s.Progress != nil {
+		fmt.Fprintln(s.Progress, progress)
+	}
+	return err
+}
+
+type progress struct {
+	total     int
+	completed *int64
+	start     time.Time
+}
+
+func newProgress(total int, completed *int64) *progress {
+	return &progress{total, completed, time.Now()}
+}
+
+// String formats completed/total, runs per second and the estimated time left
+func (p *progress) String() string {
+	completed := int(atomic.LoadInt64(p.completed))
+	elapsed := time.Since(p.start)
+	rate := float64(completed) / elapsed.Seconds()
+	eta := "unknown"
+	if completed > 0 {
+		remaining := time.Duration(float64(p.total-completed) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+	percent := 100.0
+	if p.total > 0 {
+		percent = 100 * float64(completed) / float64(p.total)
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%) %.2f runs/s ETA %s", completed, p.total, percent, rate, eta)
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"errors"
+	"io"
+	ent "uniswap-simulator/lib/transaction"
+)
+
+// ErrNotShared is returned by Shared.Run for executions that do not replay the same pools and time range
+var ErrNotShared = errors.New("executions do not share their pools")
+
+// Shared replays one history for several executions whose strategies provide liquidity in the same pool instances
+// as different owners, see strategy.NewShared. The strategies see each other's liquidity,
+// share the fees of the pool and move the price for each other.
+// Every transaction is replayed once; before and after it the executions act in the order of Executions.
+type Shared struct {
+	Executions   []*Execution
+	Transactions ent.Source
+}
+
+func (s *Shared) Run() error {
+	if len(s.Executions) == 0 {
+		return nil
+	}
+	for _, e := range s.Executions {
+		e.prepare()
+	}
+	first := s.Executions[0]
+	for _, e := range s.Executions[1:] {
+		if e.StartTime != first.StartTime || e.EndTime != first.EndTime || len(e.pools) != len(first.pools) {
+			return ErrNotShared
+		}
+		for i := range e.pools {
+			if e.pools[i] != first.pools[i] {
+				return ErrNotShared
+			}
+		}
+	}
+
+	transactions, err := s.Transactions.Open()
+	if err != nil {
+		return err
+	}
+	defer transactions.Close()
+	for {
+		trans, err := transactions.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if trans.Timestamp > first.EndTime {
+			break
+		}
+		for _, e := range s.Executions {
+			if err := e.before(trans); err != nil {
+				return err
+			}
+		}
+		pool, err := first.pool(trans)
+		if err != nil {
+			return err
+		}
+		tickBefore := pool.TickCurrent
+		if err := replay(pool, trans); err != nil {
+			return &TransactionError{trans.ID, trans.Type, trans.Timestamp, err}
+		}
+		for _, e := range s.Executions {
+			e.check(pool, trans)
+			if err := e.after(trans, tickBefore); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range s.Executions {
+		if err := e.finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
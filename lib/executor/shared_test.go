@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"math"
+	"testing"
+	ppool "uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/result"
+	strat "uniswap-simulator/lib/strategy"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+func feeShare(e *Execution) float64 {
+	r := result.NewRunResult(e.AmountUSDSnapshots)
+	r.Attribute(e.Attribution())
+	return r.FeeShare
+}
+
+func TestShared(t *testing.T) {
+	pool := testPool(t)
+	// Swaps back and forth inside the interval of the strategies
+	transactions := ent.Slice{}
+	for timestamp := 0; timestamp < 24*3600; timestamp += 600 {
+		trans := ent.Transaction{Type: "Swap", Timestamp: timestamp, Amount0: new(ui.Int), Amount1: new(ui.Int)}
+		if timestamp%1200 == 0 {
+			trans.Amount0 = ui.NewInt(100_000_000)
+		} else {
+			trans.Amount1 = ui.NewInt(30_000_000_000_000_000)
+		}
+		transactions = append(transactions, trans)
+	}
+	amount0, amount1 := ui.NewInt(1_000_000_000), ui.NewInt(300_000_000_000_000_000)
+	params := strat.Params{"interval_width": 1000}
+
+	solo, err := strat.New("range_exit", amount0, amount1, pool, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soloExecution := CreateExecution(solo, 0, math.MaxInt64, 1<<30, 3600, 60, transactions)
+	if err := soloExecution.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := pool.Clone()
+	executions := make([]*Execution, 2)
+	for i, owner := range []string{"a", "b"} {
+		strategy, err := strat.NewShared("range_exit", owner, amount0, amount1, []*ppool.Pool{shared}, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executions[i] = CreateExecution(strategy, 0, math.MaxInt64, 1<<30, 3600, 60, nil)
+	}
+	s := Shared{Executions: executions, Transactions: transactions}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	soloShare, shareA, shareB := feeShare(soloExecution), feeShare(executions[0]), feeShare(executions[1])
+	if soloShare <= 0 || soloShare >= 1 {
+		t.Fatalf("solo fee share %v", soloShare)
+	}
+	// Each strategy dilutes the other
+	if shareA >= soloShare || shareB >= soloShare {
+		t.Errorf("shared fee shares %v %v, solo %v", shareA, shareB, soloShare)
+	}
+	if shareA+shareB <= soloShare || shareA+shareB >= 1 {
+		t.Errorf("shared fee shares %v + %v, solo %v", shareA, shareB, soloShare)
+	}
+	if math.Abs(shareA-shareB) > 0.01 {
+		t.Errorf("same strategies earned %v and %v", shareA, shareB)
+	}
+	if len(shared.Positions) != 2 {
+		t.Errorf("%d positions in the shared pool, want 2", len(shared.Positions))
+	}
+}
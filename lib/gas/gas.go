@@ -0,0 +1,127 @@
+package gas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	ui "uniswap-simulator/uint256"
+)
+
+// Units is the gas used by one action of a strategy
+type Units struct {
+	Mint    int `json:"mint"`
+	Burn    int `json:"burn"`
+	Collect int `json:"collect"`
+	Swap    int `json:"swap"`
+}
+
+// DefaultUnits are typical costs of the periphery contracts on mainnet
+var DefaultUnits = Units{
+	Mint:    400_000,
+	Burn:    150_000,
+	Collect: 120_000,
+	Swap:    130_000,
+}
+
+// Total returns the gas used by the given amounts of actions
+func (u Units) Total(mints, burns, collects, swaps int) int {
+	return mints*u.Mint + burns*u.Burn + collects*u.Collect + swaps*u.Swap
+}
+
+// Config describes a gas model in a run config.
+// Either Price (a constant gas price in wei) or PriceFile is set.
+type Config struct {
+	Units *Units `json:"units"`
+	Price string `json:"price"`
+	// PriceFile is a JSON array of {"timestamp": t, "price": "wei"}
+	PriceFile string `json:"price_file"`
+}
+
+type Model struct {
+	Units Units
+	// timestamps and prices of the gas price series, a constant price has a single entry
+	timestamps []int
+	prices     []*ui.Int
+}
+
+func Constant(units Units, price *ui.Int) *Model {
+	return &Model{units, []int{0}, []*ui.Int{price}}
+}
+
+type pricePoint struct {
+	Timestamp int    `json:"timestamp"`
+	Price     string `json:"price"`
+}
+
+func Load(c Config) (*Model, error) {
+	units := DefaultUnits
+	if c.Units != nil {
+		units = *c.Units
+	}
+	if (c.Price == "") == (c.PriceFile == "") {
+		return nil, fmt.Errorf("gas: exactly one of price and price_file must be set")
+	}
+	if c.Price != "" {
+		price, err := parsePrice(c.Price)
+		if err != nil {
+			return nil, err
+		}
+		return Constant(units, price), nil
+	}
+
+	data, err := os.ReadFile(c.PriceFile)
+	if err != nil {
+		return nil, err
+	}
+	var points []pricePoint
+	if err := json.Unmarshal(data, &points); err != nil {
+		return nil, fmt.Errorf("gas price file %s: %w", c.PriceFile, err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("gas price file %s is empty", c.PriceFile)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp < points[j].Timestamp
+	})
+	m := &Model{Units: units}
+	for _, point := range points {
+		price, err := parsePrice(point.Price)
+		if err != nil {
+			return nil, fmt.Errorf("gas price file %s: %w", c.PriceFile, err)
+		}
+		m.timestamps = append(m.timestamps, point.Timestamp)
+		m.prices = append(m.prices, price)
+	}
+	return m, nil
+}
+
+func parsePrice(s string) (*ui.Int, error) {
+	bigint, ok := new(big.Int).SetString(s, 10)
+	if !ok || bigint.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gas price %q", s)
+	}
+	price, overflow := ui.FromBig(bigint)
+	if overflow {
+		return nil, fmt.Errorf("invalid gas price %q", s)
+	}
+	return price, nil
+}
+
+// Price returns the gas price in wei at timestamp, the last price of the series at or before it.
+// Before the first entry the first price is used.
+func (m *Model) Price(timestamp int) *ui.Int {
+	i := sort.Search(len(m.timestamps), func(i int) bool {
+		return m.timestamps[i] > timestamp
+	})
+	if i > 0 {
+		i--
+	}
+	return m.prices[i]
+}
+
+// Cost returns the cost of the gas units at timestamp in wei
+func (m *Model) Cost(timestamp, units int) *ui.Int {
+	return new(ui.Int).Mul(m.Price(timestamp), ui.NewInt(uint64(units)))
+}
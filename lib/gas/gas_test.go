@@ -0,0 +1,47 @@
+package gas
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestPriceSeries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gas.json")
+	series := `[{"timestamp": 200, "price": "20"}, {"timestamp": 100, "price": "10"}, {"timestamp": 300, "price": "30"}]`
+	if err := ioutil.WriteFile(path, []byte(series), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(Config{PriceFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for timestamp, want := range map[int]uint64{0: 10, 100: 10, 199: 10, 200: 20, 250: 20, 300: 30, 1000: 30} {
+		if got := m.Price(timestamp).Uint64(); got != want {
+			t.Errorf("Price(%d) = %d, want %d", timestamp, got, want)
+		}
+	}
+	if got := m.Cost(250, 1000).Uint64(); got != 20000 {
+		t.Errorf("Cost(250, 1000) = %d, want 20000", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	m, err := Load(Config{Price: "30000000000", Units: &Units{Mint: 1, Burn: 2, Collect: 3, Swap: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Price(12345).Eq(ui.NewInt(30000000000)) {
+		t.Errorf("constant price %v", m.Price(12345))
+	}
+	if got := m.Units.Total(1, 1, 2, 1); got != 1+2+6+4 {
+		t.Errorf("Total = %d", got)
+	}
+	if _, err := Load(Config{}); err == nil {
+		t.Error("loaded a model without price")
+	}
+	if _, err := Load(Config{Price: "-1"}); err == nil {
+		t.Error("loaded a negative price")
+	}
+}
@@ -0,0 +1,207 @@
+package oracle
+
+import (
+	"errors"
+	ui "uniswap-simulator/uint256"
+)
+
+// MaxCardinality is the number of observation slots of the contract
+const MaxCardinality = 65535
+
+var (
+	// ErrUninitialized is returned by Observe before the first observation was written
+	ErrUninitialized = errors.New("oracle has no observations")
+	// ErrOld is returned by Observe for a time before the oldest observation
+	ErrOld = errors.New("observation older than the oldest")
+)
+
+// Observation of the Oracle library of Uniswap V3
+type Observation struct {
+	Timestamp int
+	// TickCumulative is the sum of the tick times the seconds it was current
+	TickCumulative int64
+	// SecondsPerLiquidityCumulativeX128 is the sum of the seconds divided by the in range liquidity, at least 1
+	SecondsPerLiquidityCumulativeX128 *ui.Int
+	Initialized                       bool
+}
+
+// transform returns the observation at timestamp following last, with tick and liquidity current since last
+func transform(last Observation, timestamp, tick int, liquidity *ui.Int) Observation {
+	delta := timestamp - last.Timestamp
+	if liquidity.IsZero() {
+		liquidity = ui.NewInt(1)
+	}
+	secondsPerLiquidity := new(ui.Int).Lsh(ui.NewInt(uint64(delta)), 128)
+	secondsPerLiquidity.Div(secondsPerLiquidity, liquidity)
+	return Observation{
+		Timestamp:                         timestamp,
+		TickCumulative:                    last.TickCumulative + int64(tick)*int64(delta),
+		SecondsPerLiquidityCumulativeX128: secondsPerLiquidity.Add(secondsPerLiquidity, last.SecondsPerLiquidityCumulativeX128),
+		Initialized:                       true,
+	}
+}
+
+// Oracle is the ring buffer of observations of a pool.
+// Unlike the contract it is initialized by the first Write, as the replay starts after the pool was created.
+type Oracle struct {
+	Observations    []Observation
+	Index           int
+	Cardinality     int
+	CardinalityNext int
+	// Start is the timestamp of the first observation
+	Start int
+}
+
+func New() *Oracle {
+	return &Oracle{
+		Observations:    make([]Observation, 1),
+		CardinalityNext: 1,
+	}
+}
+
+func (o *Oracle) Clone() *Oracle {
+	// Observations are never changed in place, so copying the slice is enough
+	observations := make([]Observation, len(o.Observations))
+	copy(observations, o.Observations)
+	return &Oracle{
+		Observations:    observations,
+		Index:           o.Index,
+		Cardinality:     o.Cardinality,
+		CardinalityNext: o.CardinalityNext,
+		Start:           o.Start,
+	}
+}
+
+// Write records the tick and liquidity current until timestamp.
+// Only the first write of a timestamp is recorded.
+func (o *Oracle) Write(timestamp, tick int, liquidity *ui.Int) {
+	if o.Cardinality == 0 {
+		o.Observations[0] = Observation{timestamp, 0, ui.NewInt(0), true}
+		o.Cardinality = 1
+		o.Start = timestamp
+		return
+	}
+	last := o.Observations[o.Index]
+	if last.Timestamp == timestamp {
+		return
+	}
+	// The buffer only grows once the last slot was written
+	if o.CardinalityNext > o.Cardinality && o.Index == o.Cardinality-1 {
+		o.Cardinality = o.CardinalityNext
+	}
+	o.Index = (o.Index + 1) % o.Cardinality
+	o.Observations[o.Index] = transform(last, timestamp, tick, liquidity)
+}
+
+// Grow makes room for next observations, at most MaxCardinality
+func (o *Oracle) Grow(next int) {
+	if next > MaxCardinality {
+		next = MaxCardinality
+	}
+	if next <= o.CardinalityNext {
+		return
+	}
+	o.Observations = append(o.Observations, make([]Observation, next-len(o.Observations))...)
+	o.CardinalityNext = next
+}
+
+// Observe returns the cumulatives secondsAgos before time, interpolating between the observations.
+// tick and liquidity are the current ones of the pool.
+func (o *Oracle) Observe(time int, secondsAgos []int, tick int, liquidity *ui.Int) (tickCumulatives []int64, secondsPerLiquidityCumulativeX128s []*ui.Int, err error) {
+	if o.Cardinality == 0 {
+		return nil, nil, ErrUninitialized
+	}
+	tickCumulatives = make([]int64, len(secondsAgos))
+	secondsPerLiquidityCumulativeX128s = make([]*ui.Int, len(secondsAgos))
+	for i, secondsAgo := range secondsAgos {
+		observation, err := o.observeSingle(time, secondsAgo, tick, liquidity)
+		if err != nil {
+			return nil, nil, err
+		}
+		tickCumulatives[i] = observation.TickCumulative
+		secondsPerLiquidityCumulativeX128s[i] = observation.SecondsPerLiquidityCumulativeX128.Clone()
+	}
+	return
+}
+
+func (o *Oracle) observeSingle(time, secondsAgo, tick int, liquidity *ui.Int) (Observation, error) {
+	if secondsAgo == 0 {
+		last := o.Observations[o.Index]
+		if last.Timestamp != time {
+			last = transform(last, time, tick, liquidity)
+		}
+		return last, nil
+	}
+
+	target := time - secondsAgo
+	beforeOrAt, atOrAfter, err := o.surroundingObservations(target, tick, liquidity)
+	if err != nil {
+		return Observation{}, err
+	}
+	if target == beforeOrAt.Timestamp {
+		return beforeOrAt, nil
+	}
+	if target == atOrAfter.Timestamp {
+		return atOrAfter, nil
+	}
+	// In the middle
+	observationTimeDelta := atOrAfter.Timestamp - beforeOrAt.Timestamp
+	targetDelta := target - beforeOrAt.Timestamp
+	secondsPerLiquidity := new(ui.Int).Sub(atOrAfter.SecondsPerLiquidityCumulativeX128, beforeOrAt.SecondsPerLiquidityCumulativeX128)
+	secondsPerLiquidity.Mul(secondsPerLiquidity, ui.NewInt(uint64(targetDelta)))
+	secondsPerLiquidity.Div(secondsPerLiquidity, ui.NewInt(uint64(observationTimeDelta)))
+	return Observation{
+		Timestamp:                         target,
+		TickCumulative:                    beforeOrAt.TickCumulative + (atOrAfter.TickCumulative-beforeOrAt.TickCumulative)/int64(observationTimeDelta)*int64(targetDelta),
+		SecondsPerLiquidityCumulativeX128: secondsPerLiquidity.Add(secondsPerLiquidity, beforeOrAt.SecondsPerLiquidityCumulativeX128),
+		Initialized:                       true,
+	}, nil
+}
+
+// surroundingObservations returns the observations at or around target.
+// atOrAfter is only set if target is not beforeOrAt.
+func (o *Oracle) surroundingObservations(target, tick int, liquidity *ui.Int) (beforeOrAt, atOrAfter Observation, err error) {
+	beforeOrAt = o.Observations[o.Index]
+	if beforeOrAt.Timestamp <= target {
+		if beforeOrAt.Timestamp == target {
+			return beforeOrAt, Observation{}, nil
+		}
+		return beforeOrAt, transform(beforeOrAt, target, tick, liquidity), nil
+	}
+
+	// The oldest observation
+	beforeOrAt = o.Observations[(o.Index+1)%o.Cardinality]
+	if !beforeOrAt.Initialized {
+		beforeOrAt = o.Observations[0]
+	}
+	if beforeOrAt.Timestamp > target {
+		return Observation{}, Observation{}, ErrOld
+	}
+	beforeOrAt, atOrAfter = o.binarySearch(target)
+	return beforeOrAt, atOrAfter, nil
+}
+
+// binarySearch finds the observations around target, which must be between the oldest and the newest
+func (o *Oracle) binarySearch(target int) (beforeOrAt, atOrAfter Observation) {
+	l := (o.Index + 1) % o.Cardinality
+	r := l + o.Cardinality - 1
+	for {
+		i := (l + r) / 2
+		beforeOrAt = o.Observations[i%o.Cardinality]
+		// Not written yet, the oldest is further on
+		if !beforeOrAt.Initialized {
+			l = i + 1
+			continue
+		}
+		atOrAfter = o.Observations[(i+1)%o.Cardinality]
+		targetAtOrAfter := beforeOrAt.Timestamp <= target
+		if targetAtOrAfter && target <= atOrAfter.Timestamp {
+			return
+		}
+		if !targetAtOrAfter {
+			r = i - 1
+		} else {
+			l = i + 1
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package oracle
+
+import (
+	"errors"
+	"testing"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestObserve(t *testing.T) {
+	o := New()
+	liquidity := ui.NewInt(1 << 20)
+	if _, _, err := o.Observe(0, []int{0}, 0, liquidity); !errors.Is(err, ErrUninitialized) {
+		t.Fatalf("observe before the first write: %v", err)
+	}
+	o.Grow(3)
+	// Tick 10 from 100 to 110, 20 until 130, then 30
+	o.Write(100, 0, liquidity)
+	o.Write(110, 10, liquidity)
+	o.Write(110, 99, liquidity)
+	o.Write(130, 20, liquidity)
+
+	tickCumulatives, secondsPerLiquidity, err := o.Observe(140, []int{40, 30, 25, 10, 0}, 30, liquidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{0, 100, 200, 500, 800}
+	for i := range want {
+		if tickCumulatives[i] != want[i] {
+			t.Errorf("tick cumulatives %v, want %v", tickCumulatives, want)
+			break
+		}
+	}
+	// 40 seconds at a liquidity of 2^20
+	if !secondsPerLiquidity[4].Eq(new(ui.Int).Lsh(ui.NewInt(40), 108)) {
+		t.Errorf("seconds per liquidity %v", secondsPerLiquidity[4])
+	}
+	if _, _, err := o.Observe(140, []int{41}, 30, liquidity); !errors.Is(err, ErrOld) {
+		t.Errorf("observe before the oldest: %v", err)
+	}
+
+	// The ring buffer of 3 drops the observation of 100
+	o.Write(150, 30, liquidity)
+	if _, _, err := o.Observe(150, []int{45}, 30, liquidity); !errors.Is(err, ErrOld) {
+		t.Errorf("observe an overwritten observation: %v", err)
+	}
+	tickCumulatives, _, err = o.Observe(150, []int{40, 5}, 30, liquidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tickCumulatives[0] != 100 || tickCumulatives[1] != 950 {
+		t.Errorf("tick cumulatives %v, want [100 950]", tickCumulatives)
+	}
+}
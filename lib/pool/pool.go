@@ -1,9 +1,11 @@
 package pool
 
 import (
+	"errors"
+	"fmt"
 	cons "uniswap-simulator/lib/constants"
 	"uniswap-simulator/lib/fullmath"
-	"uniswap-simulator/lib/invariant"
+	"uniswap-simulator/lib/oracle"
 	"uniswap-simulator/lib/position"
 	"uniswap-simulator/lib/sqrtprice_math"
 	"uniswap-simulator/lib/swapmath"
@@ -12,6 +14,18 @@ import (
 	ui "uniswap-simulator/uint256"
 )
 
+var (
+	ErrInvalidTickRange = errors.New("invalid tick range")
+	ErrZeroAmount       = errors.New("amount must not be zero")
+	ErrPriceLimit       = errors.New("sqrt price limit out of range")
+	ErrNoLiquidity      = errors.New("pool has no liquidity")
+	ErrPositionNotFound = errors.New("position not found")
+	// ErrInvalidFeeProtocol is returned for a protocol fee other than 0 or 1/4 to 1/10
+	ErrInvalidFeeProtocol = errors.New("fee protocol must be 0 or in [4, 10]")
+	// ErrInsufficientLiquidity is returned for burning more than the liquidity of the position of the owner
+	ErrInsufficientLiquidity = errors.New("position has not enough liquidity")
+)
+
 type StepComputations struct {
 	sqrtPriceStartX96 *ui.Int
 	tickNext          int
@@ -42,17 +56,75 @@ type Pool struct {
 	TickSpacing          int
 	TickCurrent          int
 	TickData             *td.TickData
-	Positions            map[string]*position.Info
+	Positions            map[PositionKey]*position.Info
+	// LPFees are the fees paid to the liquidity of the pool by all swaps and flashes
+	LPFees0 *ui.Int
+	LPFees1 *ui.Int
+	// FeeProtocol is the protocol fee of slot0, 1/x of the fees of token0 in the lower 4 bits
+	// and of token1 in the upper 4 bits, 0 is off
+	FeeProtocol uint8
+	// ProtocolFees are the protocol fees not collected yet
+	ProtocolFees0 *ui.Int
+	ProtocolFees1 *ui.Int
+	// Timestamp is the block timestamp of the replayed transaction, the executor sets it before every transaction
+	Timestamp int
+	// Oracle is written with Timestamp like the observations of the contract
+	Oracle *oracle.Oracle
+	// Actions counts the calls of the strategy methods and swaps, so the executor can charge gas
+	Actions ActionCounts
+}
+
+// PositionKey identifies a position like the key of the positions mapping of the contract
+type PositionKey struct {
+	Owner     string
+	TickLower int
+	TickUpper int
+}
+
+type ActionCounts struct {
+	Mint    int
+	Burn    int
+	Collect int
+	Swap    int
+}
+
+// Sub returns the actions since before
+func (a ActionCounts) Sub(before ActionCounts) ActionCounts {
+	return ActionCounts{a.Mint - before.Mint, a.Burn - before.Burn, a.Collect - before.Collect, a.Swap - before.Swap}
 }
 
-func NewPool(token0, token1 string, fee int, sqrtRatioX96 *ui.Int) *Pool {
-	invariant.Invariant(fee >= 0 && fee <= 1000000, "fee must be between 0 and 1000000")
-	tickSpacing := cons.TickSpaces[fee]
+// MaxTickSpacing is the largest tick spacing the Uniswap V3 factory accepts
+const MaxTickSpacing = 16383
+
+// NewPool creates a pool of one of the fee tiers in constants.TickSpaces
+func NewPool(token0, token1 string, fee int, sqrtRatioX96 *ui.Int) (*Pool, error) {
+	tickSpacing, ok := cons.TickSpaces[fee]
+	if !ok {
+		return nil, fmt.Errorf("fee %d has no default tick spacing, set it explicitly", fee)
+	}
+	return NewPoolWithTickSpacing(token0, token1, fee, tickSpacing, sqrtRatioX96)
+}
+
+// NewPoolWithTickSpacing creates a pool with a custom fee in hundredths of a bip and tick spacing,
+// e.g. for hypothetical fee tiers
+func NewPoolWithTickSpacing(token0, token1 string, fee, tickSpacing int, sqrtRatioX96 *ui.Int) (*Pool, error) {
+	if fee < 0 || fee >= 1000000 {
+		return nil, fmt.Errorf("fee %d must be in [0, 1000000)", fee)
+	}
+	if tickSpacing <= 0 || tickSpacing > MaxTickSpacing {
+		return nil, fmt.Errorf("tick spacing %d must be in [1, %d]", tickSpacing, MaxTickSpacing)
+	}
+	if sqrtRatioX96.Lt(tickmath.MinSqrtRatio) || !sqrtRatioX96.Lt(tickmath.MaxSqrtRatio) {
+		return nil, fmt.Errorf("sqrt price %v must be in [MinSqrtRatio, MaxSqrtRatio)", sqrtRatioX96.ToBig())
+	}
 	liquidity := ui.NewInt(0)
-	tickCurrent := tickmath.TM.GetTickAtSqrtRatio(sqrtRatioX96)
+	tickCurrent, err := tickmath.TM.GetTickAtSqrtRatio(sqrtRatioX96)
+	if err != nil {
+		return nil, err
+	}
 	tickData := td.NewTickData(tickSpacing)
 
-	positions := make(map[string]*position.Info)
+	positions := make(map[PositionKey]*position.Info)
 	pool := &Pool{
 		token0,
 		token1,
@@ -65,12 +137,20 @@ func NewPool(token0, token1 string, fee int, sqrtRatioX96 *ui.Int) *Pool {
 		tickCurrent,
 		tickData,
 		positions,
+		ui.NewInt(0),
+		ui.NewInt(0),
+		0,
+		ui.NewInt(0),
+		ui.NewInt(0),
+		0,
+		oracle.New(),
+		ActionCounts{},
 	}
-	return pool
+	return pool, nil
 }
 
 func (p *Pool) Clone() *Pool {
-	positions := make(map[string]*position.Info)
+	positions := make(map[PositionKey]*position.Info)
 	for k, v := range p.Positions {
 		positions[k] = v.Clone()
 	}
@@ -86,9 +166,17 @@ func (p *Pool) Clone() *Pool {
 		TickCurrent:          p.TickCurrent,
 		TickData:             p.TickData.Clone(),
 		Positions:            positions,
+		LPFees0:              p.LPFees0.Clone(),
+		LPFees1:              p.LPFees1.Clone(),
+		FeeProtocol:          p.FeeProtocol,
+		ProtocolFees0:        p.ProtocolFees0.Clone(),
+		ProtocolFees1:        p.ProtocolFees1.Clone(),
+		Timestamp:            p.Timestamp,
+		Oracle:               p.Oracle.Clone(),
+		Actions:              p.Actions,
 	}
 }
-func (p *Pool) updatePosition(tickLower, tickUpper int, amount *ui.Int) (pos *position.Info) {
+func (p *Pool) updatePosition(owner string, tickLower, tickUpper int, amount *ui.Int) (pos *position.Info, err error) {
 	var clearLower, clearUpper bool
 
 	if !amount.IsZero() {
@@ -96,45 +184,70 @@ func (p *Pool) updatePosition(tickLower, tickUpper int, amount *ui.Int) (pos *po
 		clearUpper = p.TickData.UpdateTick(tickUpper, p.TickCurrent, amount, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128, true)
 	}
 
-	feeGrowthInside0X128, feeGrowthInside1X128 := p.TickData.GetFeeGrowthInside(tickLower, tickUpper, p.TickCurrent, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128)
-	searchString := string(tickLower) + "-" + string(tickUpper)
-	pos = p.Positions[searchString]
+	feeGrowthInside0X128, feeGrowthInside1X128, err := p.TickData.GetFeeGrowthInside(tickLower, tickUpper, p.TickCurrent, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128)
+	if err != nil {
+		return nil, err
+	}
+	key := PositionKey{owner, tickLower, tickUpper}
+	pos = p.Positions[key]
 	if pos == nil {
 		pos = position.NewPosition()
-		pos.Update(amount, feeGrowthInside0X128, feeGrowthInside1X128)
-		p.Positions[searchString] = pos
-	} else {
-		pos.Update(amount, feeGrowthInside0X128, feeGrowthInside1X128)
-		p.Positions[searchString] = pos
+		p.Positions[key] = pos
 	}
+	pos.Update(amount, feeGrowthInside0X128, feeGrowthInside1X128)
 	if amount.Sign() == -1 {
-		if clearLower {
-			p.TickData.ClearTick(tickLower)
-		}
-		if clearUpper {
-			p.TickData.ClearTick(tickUpper)
+		if err = p.clearTicks(tickLower, tickUpper, clearLower, clearUpper); err != nil {
+			return nil, err
 		}
 	}
 	return
 }
 
-func (p *Pool) checkTicks(tickLower, tickUpper int) {
-	invariant.Invariant(tickLower < tickUpper, "tickLower must be less than tickUpper")
-	invariant.Invariant(tickLower >= tickmath.MinTick, "tickLower must be greater than or equal to MinTick")
-	invariant.Invariant(tickUpper <= tickmath.MaxTick, "tickUpper must be less than or equal to MaxTick")
+func (p *Pool) clearTicks(tickLower, tickUpper int, clearLower, clearUpper bool) error {
+	if clearLower {
+		if err := p.TickData.ClearTick(tickLower); err != nil {
+			return err
+		}
+	}
+	if clearUpper {
+		return p.TickData.ClearTick(tickUpper)
+	}
+	return nil
 }
 
-func (p *Pool) modifyPositionStrategy(tickLower int, tickUpper int, amount *ui.Int) (pos *position.Info, amount0, amount1 *ui.Int) {
-	p.checkTicks(tickLower, tickUpper)
-	pos = p.updatePosition(tickLower, tickUpper, amount)
+func (p *Pool) checkTicks(tickLower, tickUpper int) error {
+	if tickLower >= tickUpper || tickLower < tickmath.MinTick || tickUpper > tickmath.MaxTick {
+		return fmt.Errorf("%w: [%d, %d]", ErrInvalidTickRange, tickLower, tickUpper)
+	}
+	return nil
+}
+
+func (p *Pool) modifyOwnedPosition(owner string, tickLower int, tickUpper int, amount *ui.Int) (pos *position.Info, amount0, amount1 *ui.Int, err error) {
+	if err = p.checkTicks(tickLower, tickUpper); err != nil {
+		return
+	}
+	// Burns, including pokes of zero, need an existing position
+	if amount.Sign() <= 0 {
+		if pos = p.Positions[PositionKey{owner, tickLower, tickUpper}]; pos == nil || pos.Liquidity.Lt(new(ui.Int).Neg(amount)) {
+			return nil, nil, nil, fmt.Errorf("%w: %s [%d, %d]", ErrInsufficientLiquidity, owner, tickLower, tickUpper)
+		}
+		// The contract reverts with NP on poking a position without liquidity
+		if amount.IsZero() && pos.Liquidity.IsZero() {
+			return nil, nil, nil, fmt.Errorf("%w: %s [%d, %d] has no liquidity to poke", ErrPositionNotFound, owner, tickLower, tickUpper)
+		}
+	}
+	if pos, err = p.updatePosition(owner, tickLower, tickUpper, amount); err != nil {
+		return
+	}
 
 	if amount.IsZero() {
-		return pos, cons.Zero.Clone(), cons.Zero.Clone()
+		return pos, cons.Zero.Clone(), cons.Zero.Clone(), nil
 	}
 	if p.TickCurrent < tickLower {
 		amount0 = sqrtprice_math.GetAmount0DeltaRounded(tickmath.TM.GetSqrtRatioAtTick(tickLower), tickmath.TM.GetSqrtRatioAtTick(tickUpper), amount)
 		amount1 = ui.NewInt(0)
 	} else if p.TickCurrent < tickUpper {
+		p.Oracle.Write(p.Timestamp, p.TickCurrent, p.Liquidity)
 		amount0 = sqrtprice_math.GetAmount0DeltaRounded(p.SqrtRatioX96, tickmath.TM.GetSqrtRatioAtTick(tickUpper), amount)
 		amount1 = sqrtprice_math.GetAmount1DeltaRounded(p.SqrtRatioX96, tickmath.TM.GetSqrtRatioAtTick(tickLower), amount)
 		p.Liquidity.Add(p.Liquidity, amount)
@@ -146,29 +259,42 @@ func (p *Pool) modifyPositionStrategy(tickLower int, tickUpper int, amount *ui.I
 	return
 }
 
-func (p *Pool) MintStrategy(tickLower int, tickUpper int, amount *ui.Int) (amount0, amount1 *ui.Int) {
-	invariant.Invariant(amount.Sgt(cons.Zero), "amount must be greater than zero")
-	_, amount0, amount1 = p.modifyPositionStrategy(tickLower, tickUpper, amount)
+// Mint adds liquidity to the position of owner and returns the amounts owed to the pool
+func (p *Pool) Mint(owner string, tickLower int, tickUpper int, amount *ui.Int) (amount0, amount1 *ui.Int, err error) {
+	if !amount.Sgt(cons.Zero) {
+		return nil, nil, ErrZeroAmount
+	}
+	if _, amount0, amount1, err = p.modifyOwnedPosition(owner, tickLower, tickUpper, amount); err != nil {
+		return nil, nil, err
+	}
+	p.Actions.Mint++
 	return
 }
 
-// BurnStrategy doesn't actually pay out. It just updates the position.
-func (p *Pool) BurnStrategy(tickLower int, tickUpper int, amount *ui.Int) (*ui.Int, *ui.Int) {
-
+// Burn doesn't actually pay out. It just updates the position of owner and credits the amounts to its tokens owed.
+// Burning zero pokes the position to credit its fees.
+func (p *Pool) Burn(owner string, tickLower int, tickUpper int, amount *ui.Int) (*ui.Int, *ui.Int, error) {
 	amountMinus := new(ui.Int)
 	amountMinus.Neg(amount)
-	pos, amount0Int, amount1Int := p.modifyPositionStrategy(tickLower, tickUpper, amountMinus)
+	pos, amount0Int, amount1Int, err := p.modifyOwnedPosition(owner, tickLower, tickUpper, amountMinus)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Actions.Burn++
 	amount0, amount1 := new(ui.Int).Neg(amount0Int), new(ui.Int).Neg(amount1Int)
 	pos.TokensOwed0.Add(pos.TokensOwed0, amount0)
 	pos.TokensOwed1.Add(pos.TokensOwed1, amount1)
 	// return is kinda useless
-	return amount0, amount1
+	return amount0, amount1, nil
 }
 
-// CollectStrategy Always Collect all
-func (p *Pool) CollectStrategy(tickLower int, tickUpper int) (amount0, amount1 *ui.Int) {
-	searchString := string(tickLower) + "-" + string(tickUpper)
-	pos := p.Positions[searchString]
+// Collect Always Collect all tokens owed to the position of owner
+func (p *Pool) Collect(owner string, tickLower int, tickUpper int) (amount0, amount1 *ui.Int, err error) {
+	pos := p.Positions[PositionKey{owner, tickLower, tickUpper}]
+	if pos == nil {
+		return nil, nil, fmt.Errorf("%w: %s [%d, %d]", ErrPositionNotFound, owner, tickLower, tickUpper)
+	}
+	p.Actions.Collect++
 
 	amount0 = pos.TokensOwed0.Clone()
 	amount1 = pos.TokensOwed1.Clone()
@@ -176,68 +302,210 @@ func (p *Pool) CollectStrategy(tickLower int, tickUpper int) (amount0, amount1 *
 	pos.TokensOwed0.Set(cons.Zero)
 	pos.TokensOwed1.Set(cons.Zero)
 
-	return
+	return amount0, amount1, nil
+}
+
+// UncollectedFees returns the tokens owed and the fees accrued by the position of owner since it was last touched
+func (p *Pool) UncollectedFees(owner string, tickLower int, tickUpper int) (amount0, amount1 *ui.Int, err error) {
+	pos := p.Positions[PositionKey{owner, tickLower, tickUpper}]
+	if pos == nil {
+		return ui.NewInt(0), ui.NewInt(0), nil
+	}
+	if pos.Liquidity.IsZero() {
+		return pos.TokensOwed0.Clone(), pos.TokensOwed1.Clone(), nil
+	}
+	feeGrowthInside0X128, feeGrowthInside1X128, err := p.TickData.GetFeeGrowthInside(tickLower, tickUpper, p.TickCurrent, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128)
+	if err != nil {
+		return nil, nil, err
+	}
+	amount0, amount1 = pos.UncollectedFees(feeGrowthInside0X128, feeGrowthInside1X128)
+	return amount0, amount1, nil
+}
 
+// FeesEarned returns the fees credited to all positions of owner.
+// Fees accrued since a position was last touched are only included once it is burned or poked.
+func (p *Pool) FeesEarned(owner string) (fees0, fees1 *ui.Int) {
+	fees0, fees1 = ui.NewInt(0), ui.NewInt(0)
+	for key, pos := range p.Positions {
+		if key.Owner != owner {
+			continue
+		}
+		fees0.Add(fees0, pos.FeesEarned0)
+		fees1.Add(fees1, pos.FeesEarned1)
+	}
+	return
 }
 
-func (p *Pool) Mint(tickLower int, tickUpper int, amount *ui.Int) {
-	invariant.Invariant(amount.Sgt(cons.Zero), "amount must be greater than zero")
-	p.modifyPosition(tickLower, tickUpper, amount)
+// AddLiquidity adds liquidity to the range without a position.
+// It replays the mints of the history, whose owners are not known and whose fees are not tracked.
+func (p *Pool) AddLiquidity(tickLower int, tickUpper int, amount *ui.Int) error {
+	if !amount.Sgt(cons.Zero) {
+		return ErrZeroAmount
+	}
+	return p.modifyPosition(tickLower, tickUpper, amount)
 }
 
-func (p *Pool) Burn(tickLower int, tickUpper int, amount *ui.Int) {
+// RemoveLiquidity removes liquidity added by AddLiquidity, it replays the burns of the history
+func (p *Pool) RemoveLiquidity(tickLower int, tickUpper int, amount *ui.Int) error {
 	amountMinus := new(ui.Int)
 	amountMinus.Neg(amount)
-	p.modifyPosition(tickLower, tickUpper, amountMinus)
+	return p.modifyPosition(tickLower, tickUpper, amountMinus)
 }
 
-func (p *Pool) ExactInputSwap(inputAmount *ui.Int, token string, sqrtPriceLimitX96 *ui.Int) (*ui.Int, *ui.Int) {
+func (p *Pool) ExactInputSwap(inputAmount *ui.Int, token string, sqrtPriceLimitX96 *ui.Int) (*ui.Int, *ui.Int, error) {
 	zeroForOne := token == p.Token0
-	return p.swap(zeroForOne, inputAmount, sqrtPriceLimitX96)
+	amount0, amount1, _, err := p.swap(zeroForOne, inputAmount, sqrtPriceLimitX96, nil)
+	return amount0, amount1, err
 }
 
-func (p *Pool) ExactOutputSwap(outputAmount *ui.Int, token string, sqrtPriceLimitX96 *ui.Int) (*ui.Int, *ui.Int) {
+// ExactOutputSwap swaps for outputAmount of token, the amount is negated like amountSpecified of the contract
+func (p *Pool) ExactOutputSwap(outputAmount *ui.Int, token string, sqrtPriceLimitX96 *ui.Int) (*ui.Int, *ui.Int, error) {
 	zeroForOne := token == p.Token1
-	return p.swap(zeroForOne, outputAmount, sqrtPriceLimitX96)
+	amount0, amount1, _, err := p.swap(zeroForOne, new(ui.Int).Neg(outputAmount), sqrtPriceLimitX96, nil)
+	return amount0, amount1, err
 }
 
-func (p *Pool) modifyPosition(lower int, upper int, amount *ui.Int) {
-	p.checkTicks(lower, upper)
+// QuoteExactInputSwap returns the amounts of ExactInputSwap and the price after it without changing the pool,
+// like the Quoter of the periphery. The liquidity of the positions without is left out, as if they were burnt first.
+func (p *Pool) QuoteExactInputSwap(inputAmount *ui.Int, token string, sqrtPriceLimitX96 *ui.Int, without ...PositionKey) (amount0, amount1, sqrtPriceX96 *ui.Int, err error) {
+	q := &quote{new(ui.Int), make(map[int]*ui.Int)}
+	for _, key := range without {
+		pos := p.Positions[key]
+		if pos == nil {
+			return nil, nil, nil, fmt.Errorf("%w: %s [%d, %d]", ErrPositionNotFound, key.Owner, key.TickLower, key.TickUpper)
+		}
+		q.add(key.TickLower, pos.Liquidity)
+		q.add(key.TickUpper, new(ui.Int).Neg(pos.Liquidity))
+		if key.TickLower <= p.TickCurrent && p.TickCurrent < key.TickUpper {
+			q.liquidity.Add(q.liquidity, pos.Liquidity)
+		}
+	}
+	zeroForOne := token == p.Token0
+	return p.swap(zeroForOne, inputAmount, sqrtPriceLimitX96, q)
+}
+
+// quote makes swap leave the pool unchanged and leave out the liquidity of positions
+type quote struct {
+	// liquidity of the positions in range and the liquidityNet they add at their ticks
+	liquidity    *ui.Int
+	liquidityNet map[int]*ui.Int
+}
+
+func (q *quote) add(tick int, liquidityNet *ui.Int) {
+	if net, ok := q.liquidityNet[tick]; ok {
+		net.Add(net, liquidityNet)
+		return
+	}
+	q.liquidityNet[tick] = liquidityNet.Clone()
+}
+
+// cross returns the liquidityNet of tick without the left out positions, the tick itself is not crossed
+func (q *quote) cross(tickData *td.TickData, tick int) (*ui.Int, error) {
+	info, err := tickData.GetTick(tick)
+	if err != nil {
+		return nil, err
+	}
+	liquidityNet := info.LiquidityNet.Clone()
+	if net, ok := q.liquidityNet[tick]; ok {
+		liquidityNet.Sub(liquidityNet, net)
+	}
+	return liquidityNet, nil
+}
+
+func (p *Pool) modifyPosition(lower int, upper int, amount *ui.Int) error {
+	if err := p.checkTicks(lower, upper); err != nil {
+		return err
+	}
 	clearLower := p.TickData.UpdateTick(lower, p.TickCurrent, amount, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128, false)
 	clearUpper := p.TickData.UpdateTick(upper, p.TickCurrent, amount, p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128, true)
 
-	if clearLower {
-		p.TickData.ClearTick(lower)
-	}
-	if clearUpper {
-		p.TickData.ClearTick(upper)
+	if err := p.clearTicks(lower, upper, clearLower, clearUpper); err != nil {
+		return err
 	}
 
 	if p.TickCurrent >= lower && p.TickCurrent < upper {
+		p.Oracle.Write(p.Timestamp, p.TickCurrent, p.Liquidity)
 		p.Liquidity.Add(p.Liquidity, amount)
 	}
+	return nil
+}
+
+// Observe returns the tick and seconds per liquidity cumulatives of every secondsAgos before Timestamp
+func (p *Pool) Observe(secondsAgos []int) (tickCumulatives []int64, secondsPerLiquidityCumulativeX128s []*ui.Int, err error) {
+	return p.Oracle.Observe(p.Timestamp, secondsAgos, p.TickCurrent, p.Liquidity)
+}
+
+// IncreaseObservationCardinalityNext makes the oracle keep up to next observations
+func (p *Pool) IncreaseObservationCardinalityNext(next int) {
+	p.Oracle.Grow(next)
+}
+
+// SetFeeProtocol sets the protocol fee of token0 and token1 to 1/feeProtocol of the swap fees, 0 turns it off
+func (p *Pool) SetFeeProtocol(feeProtocol0, feeProtocol1 int) error {
+	for _, feeProtocol := range []int{feeProtocol0, feeProtocol1} {
+		if feeProtocol != 0 && (feeProtocol < 4 || feeProtocol > 10) {
+			return fmt.Errorf("%w: %d", ErrInvalidFeeProtocol, feeProtocol)
+		}
+	}
+	p.FeeProtocol = uint8(feeProtocol0 + feeProtocol1<<4)
+	return nil
+}
+
+// CollectProtocol pays out the protocol fees up to the requested amounts.
+// Like the contract it leaves one unit of a fully collected token in the pool.
+func (p *Pool) CollectProtocol(amount0Requested, amount1Requested *ui.Int) (amount0, amount1 *ui.Int) {
+	collect := func(requested, fees *ui.Int) *ui.Int {
+		amount := requested.Clone()
+		if fees.Lt(amount) {
+			amount.Set(fees)
+		}
+		if !amount.IsZero() && amount.Eq(fees) {
+			amount.Sub(amount, cons.One)
+		}
+		fees.Sub(fees, amount)
+		return amount
+	}
+	return collect(amount0Requested, p.ProtocolFees0), collect(amount1Requested, p.ProtocolFees1)
 }
 
 // Flash
 // Use amounts instead of Paid
-func (p *Pool) Flash(amount0 *ui.Int, amount1 *ui.Int) {
-	invariant.Invariant(p.Liquidity.Sign() == 1, "Liquidity must be greater than zero")
+func (p *Pool) Flash(amount0 *ui.Int, amount1 *ui.Int) error {
+	if p.Liquidity.Sign() != 1 {
+		return ErrNoLiquidity
+	}
 	fee0 := fullmath.MulDivRoundingUp(amount0, ui.NewInt(uint64(p.Fee)), ui.NewInt(1_000_000))
 	fee1 := fullmath.MulDivRoundingUp(amount1, ui.NewInt(uint64(p.Fee)), ui.NewInt(1_000_000))
+	if feeProtocol0 := p.FeeProtocol % 16; feeProtocol0 > 0 {
+		protocolFee0 := new(ui.Int).Div(fee0, ui.NewInt(uint64(feeProtocol0)))
+		p.ProtocolFees0.Add(p.ProtocolFees0, protocolFee0)
+		fee0.Sub(fee0, protocolFee0)
+	}
+	if feeProtocol1 := p.FeeProtocol >> 4; feeProtocol1 > 0 {
+		protocolFee1 := new(ui.Int).Div(fee1, ui.NewInt(uint64(feeProtocol1)))
+		p.ProtocolFees1.Add(p.ProtocolFees1, protocolFee1)
+		fee1.Sub(fee1, protocolFee1)
+	}
 
 	fee0Q128 := fullmath.MulDiv(fee0, cons.Q128, p.Liquidity)
 	fee1Q128 := fullmath.MulDiv(fee1, cons.Q128, p.Liquidity)
 
 	p.FeeGrowthGlobal0X128.Add(p.FeeGrowthGlobal0X128, fee0Q128)
 	p.FeeGrowthGlobal1X128.Add(p.FeeGrowthGlobal1X128, fee1Q128)
-
+	p.LPFees0.Add(p.LPFees0, fee0)
+	p.LPFees1.Add(p.LPFees1, fee1)
+	return nil
 }
 
 // swap
-// amountSpecified can be negative
-func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96In *ui.Int) (*ui.Int, *ui.Int) {
-
-	invariant.Invariant(!amountSpecified.IsZero(), "amount must be not zero")
+// amountSpecified can be negative. With a quote the pool is not changed.
+func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96In *ui.Int, q *quote) (amount0, amount1, sqrtPriceX96 *ui.Int, err error) {
+	if amountSpecified.IsZero() {
+		return nil, nil, nil, ErrZeroAmount
+	}
+	if q == nil {
+		p.Actions.Swap++
+	}
 
 	sqrtPriceLimitX96 := sqrtPriceLimitX96In.Clone()
 	// Allow Zero as sqrtPriceLimitX96
@@ -255,14 +523,19 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 	} else {
 		cond = sqrtPriceLimitX96.Cmp(p.SqrtRatioX96) == 1 && sqrtPriceLimitX96.Cmp(tickmath.MaxSqrtRatio) == -1
 	}
-	invariant.Invariant(cond, "sqrtPriceLimitX96 must be between min and max")
+	if !cond {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrPriceLimit, sqrtPriceLimitX96.ToBig())
+	}
 
 	exactInput := amountSpecified.Sign() >= 0
 	var feeGrowthGlobalX128 *ui.Int
+	var feeProtocol uint8
 	if zeroForOne {
 		feeGrowthGlobalX128 = p.FeeGrowthGlobal0X128
+		feeProtocol = p.FeeProtocol % 16
 	} else {
 		feeGrowthGlobalX128 = p.FeeGrowthGlobal1X128
+		feeProtocol = p.FeeProtocol >> 4
 	}
 	state := stateStruct{
 		amountSpecified.Clone(),
@@ -272,6 +545,10 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 		feeGrowthGlobalX128.Clone(),
 		p.Liquidity.Clone(),
 	}
+	if q != nil {
+		state.liquidity.Sub(state.liquidity, q.liquidity)
+	}
+	lpFees, protocolFees := ui.NewInt(0), ui.NewInt(0)
 
 	//start while loop
 	for !state.amountSpecifiedRemainingI.IsZero() && state.sqrtPriceX96.Cmp(sqrtPriceLimitX96) != 0 {
@@ -301,9 +578,12 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 			}
 		}
 
-		state.sqrtPriceX96, step.amountIn, step.amountOut, step.feeAmount =
+		state.sqrtPriceX96, step.amountIn, step.amountOut, step.feeAmount, err =
 			swapmath.ComputeSwapStep(state.sqrtPriceX96,
 				targetValue, state.liquidity, state.amountSpecifiedRemainingI, p.Fee)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 
 		if exactInput {
 			state.amountSpecifiedRemainingI.Sub(state.amountSpecifiedRemainingI, new(ui.Int).Add(step.amountIn, step.feeAmount))
@@ -315,9 +595,17 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 
 		}
 
+		// The protocol takes its share of the fee before the liquidity
+		if feeProtocol > 0 {
+			delta := new(ui.Int).Div(step.feeAmount, ui.NewInt(uint64(feeProtocol)))
+			step.feeAmount = new(ui.Int).Sub(step.feeAmount, delta)
+			protocolFees.Add(protocolFees, delta)
+		}
+
 		if state.liquidity.Sign() > 0 {
 			fee := fullmath.MulDiv(step.feeAmount, cons.Q128, state.liquidity)
 			state.feeGrowthGlobalX128.Add(state.feeGrowthGlobalX128, fee)
+			lpFees.Add(lpFees, step.feeAmount)
 		}
 
 		if state.sqrtPriceX96.Cmp(step.sqrtPriceNextX96) == 0 {
@@ -330,7 +618,15 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 					feeGrowthGlobal0X128 = p.FeeGrowthGlobal0X128
 					feeGrowthGlobal1X128 = state.feeGrowthGlobalX128
 				}
-				liquidityNet := p.TickData.Cross(step.tickNext, feeGrowthGlobal0X128, feeGrowthGlobal1X128)
+				var liquidityNet *ui.Int
+				if q == nil {
+					liquidityNet, err = p.TickData.Cross(step.tickNext, feeGrowthGlobal0X128, feeGrowthGlobal1X128)
+				} else {
+					liquidityNet, err = q.cross(p.TickData, step.tickNext)
+				}
+				if err != nil {
+					return nil, nil, nil, err
+				}
 
 				if zeroForOne {
 					state.liquidity = state.liquidity.Sub(state.liquidity, liquidityNet)
@@ -344,28 +640,41 @@ func (p *Pool) swap(zeroForOne bool, amountSpecified *ui.Int, sqrtPriceLimitX96I
 				state.tick = step.tickNext
 			}
 		} else if state.sqrtPriceX96.Cmp(step.sqrtPriceStartX96) != 0 {
-			state.tick = tickmath.TM.GetTickAtSqrtRatio(state.sqrtPriceX96)
+			if state.tick, err = tickmath.TM.GetTickAtSqrtRatio(state.sqrtPriceX96); err != nil {
+				return nil, nil, nil, err
+			}
 		}
 
 	}
-	// Update Slot0
+
+	amount0, amount1 = new(ui.Int), new(ui.Int)
+	if zeroForOne == exactInput {
+		amount0.Sub(amountSpecified, state.amountSpecifiedRemainingI)
+		amount1.Set(state.amountCalculatedI)
+	} else {
+		amount0.Set(state.amountCalculatedI)
+		amount1.Sub(amountSpecified, state.amountSpecifiedRemainingI)
+	}
+	if q != nil {
+		return amount0, amount1, state.sqrtPriceX96, nil
+	}
+
+	// Update Slot0, an oracle entry is only written if the tick changed
+	if state.tick != p.TickCurrent {
+		p.Oracle.Write(p.Timestamp, p.TickCurrent, p.Liquidity)
+	}
 	p.TickCurrent = state.tick
 	p.Liquidity = state.liquidity
 	p.SqrtRatioX96 = state.sqrtPriceX96
 
 	if zeroForOne {
 		p.FeeGrowthGlobal0X128 = state.feeGrowthGlobalX128
+		p.LPFees0.Add(p.LPFees0, lpFees)
+		p.ProtocolFees0.Add(p.ProtocolFees0, protocolFees)
 	} else {
 		p.FeeGrowthGlobal1X128 = state.feeGrowthGlobalX128
+		p.LPFees1.Add(p.LPFees1, lpFees)
+		p.ProtocolFees1.Add(p.ProtocolFees1, protocolFees)
 	}
-
-	amount0, amount1 := new(ui.Int), new(ui.Int)
-	if zeroForOne == exactInput {
-		amount0.Sub(amountSpecified, state.amountSpecifiedRemainingI)
-		amount1.Set(state.amountCalculatedI)
-	} else {
-		amount0.Set(state.amountCalculatedI)
-		amount1.Sub(amountSpecified, state.amountSpecifiedRemainingI)
-	}
-	return amount0, amount1
+	return amount0, amount1, p.SqrtRatioX96, nil
 }
@@ -0,0 +1,339 @@
+package pool
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+func testPool(t *testing.T) *Pool {
+	t.Helper()
+	sqrtPrice, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
+	sqrtPriceX96, _ := ui.FromBig(sqrtPrice)
+	p, err := NewPool("USDC", "WETH", 500, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewPool(t *testing.T) {
+	sqrtPriceX96 := testPool(t).SqrtRatioX96
+	if _, err := NewPool("USDC", "WETH", 1234, sqrtPriceX96); err == nil {
+		t.Error("fee without a default tick spacing")
+	}
+	if _, err := NewPoolWithTickSpacing("USDC", "WETH", 1234, 0, sqrtPriceX96); err == nil {
+		t.Error("tick spacing 0")
+	}
+	if _, err := NewPoolWithTickSpacing("USDC", "WETH", 1234, 20, ui.NewInt(1)); err == nil {
+		t.Error("sqrt price below MinSqrtRatio")
+	}
+	p, err := NewPoolWithTickSpacing("USDC", "WETH", 1234, 20, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Fee != 1234 || p.TickSpacing != 20 {
+		t.Errorf("fee %d tick spacing %d", p.Fee, p.TickSpacing)
+	}
+}
+
+func TestUncollectedFees(t *testing.T) {
+	p := testPool(t)
+	tickLower, tickUpper := 192000, 194000
+	if _, _, err := p.Mint("a", tickLower, tickUpper, ui.NewInt(1_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.ExactInputSwap(ui.NewInt(1_000_000), p.Token0, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.ExactInputSwap(ui.NewInt(500_000_000_000_000), p.Token1, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	fees0, fees1, err := p.UncollectedFees("a", tickLower, tickUpper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees0.IsZero() || fees1.IsZero() {
+		t.Fatalf("no fees accrued: %v %v", fees0, fees1)
+	}
+	// Poke the position and collect the fees
+	if _, _, err := p.Burn("a", tickLower, tickUpper, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	collected0, collected1, err := p.Collect("a", tickLower, tickUpper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees0.Eq(collected0) || !fees1.Eq(collected1) {
+		t.Errorf("uncollected fees %v %v, collected %v %v", fees0, fees1, collected0, collected1)
+	}
+	if fees0, fees1, _ := p.UncollectedFees("a", tickLower, tickUpper); !fees0.IsZero() || !fees1.IsZero() {
+		t.Errorf("fees after collect %v %v", fees0, fees1)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	p := testPool(t)
+	tickLower, tickUpper := 192000, 194000
+	// The same range minted by two owners, b with three times the liquidity
+	if _, _, err := p.Mint("a", tickLower, tickUpper, ui.NewInt(1_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Mint("b", tickLower, tickUpper, ui.NewInt(3_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.ExactInputSwap(ui.NewInt(1_000_000), p.Token0, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.Burn("a", tickLower, tickUpper, ui.NewInt(2_000_000_000_000)); !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Errorf("burning more than the position of a: %v", err)
+	}
+	if _, _, err := p.Burn("c", tickLower, tickUpper, ui.NewInt(0)); !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Errorf("poking a position c does not have: %v", err)
+	}
+	for _, owner := range []string{"a", "b"} {
+		if _, _, err := p.Burn(owner, tickLower, tickUpper, ui.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	feesA, _ := p.FeesEarned("a")
+	feesB, _ := p.FeesEarned("b")
+	if feesA.IsZero() {
+		t.Fatal("a earned no fees")
+	}
+	// Rounding down loses at most a unit per position
+	diff := new(ui.Int).Sub(feesB, new(ui.Int).Mul(feesA, ui.NewInt(3)))
+	if diff.Gt(ui.NewInt(3)) {
+		t.Errorf("fees of a %v, b %v, want three times a", feesA, feesB)
+	}
+
+	// Only actions that succeed are counted
+	actions := p.Actions
+	if _, _, err := p.Burn("a", tickLower, tickUpper, ui.NewInt(1_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Burn("a", tickLower, tickUpper, ui.NewInt(0)); !errors.Is(err, ErrPositionNotFound) {
+		t.Errorf("poking a position without liquidity: %v", err)
+	}
+	if _, _, err := p.Mint("a", tickUpper, tickLower, ui.NewInt(1)); !errors.Is(err, ErrInvalidTickRange) {
+		t.Errorf("minting an inverted range: %v", err)
+	}
+	if _, _, err := p.Collect("c", tickLower, tickUpper); !errors.Is(err, ErrPositionNotFound) {
+		t.Errorf("collecting a position c does not have: %v", err)
+	}
+	actions.Burn++
+	if p.Actions != actions {
+		t.Errorf("actions %+v, want %+v", p.Actions, actions)
+	}
+}
+
+func TestClone(t *testing.T) {
+	build := func() *Pool {
+		p := testPool(t)
+		if err := p.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := p.Mint("lp", 190000, 200000, ui.NewInt(1_000_000_000_000_000)); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	// 15 ETH move the price above the range of the position
+	amount := ui.NewInt(15_000_000_000_000_000_000)
+	p, want := build(), build()
+	clone := p.Clone()
+	if _, _, err := clone.Burn("lp", 190000, 200000, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := clone.ExactInputSwap(amount, clone.Token1, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The burn and the swap of the clone do not change the ticks of p
+	for _, pool := range []*Pool{p, want} {
+		if _, _, err := pool.ExactInputSwap(amount, pool.Token1, ui.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := pool.Burn("lp", 190000, 200000, ui.NewInt(1_000_000_000_000_000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fees0, fees1 := p.FeesEarned("lp")
+	want0, want1 := want.FeesEarned("lp")
+	if !p.SqrtRatioX96.Eq(want.SqrtRatioX96) || !fees0.Eq(want0) || !fees1.Eq(want1) {
+		t.Errorf("price %v, fees %v %v, want %v, %v %v", p.SqrtRatioX96, fees0, fees1, want.SqrtRatioX96, want0, want1)
+	}
+}
+
+func TestProtocolFee(t *testing.T) {
+	p := testPool(t)
+	if err := p.SetFeeProtocol(3, 0); !errors.Is(err, ErrInvalidFeeProtocol) {
+		t.Errorf("fee protocol 3: %v", err)
+	}
+	if err := p.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	withoutProtocol := p.Clone()
+	if err := p.SetFeeProtocol(4, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, pool := range []*Pool{p, withoutProtocol} {
+		if _, _, err := pool.ExactInputSwap(ui.NewInt(1_000_000_000), pool.Token0, ui.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := pool.ExactInputSwap(ui.NewInt(100_000_000_000_000_000), pool.Token1, ui.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A quarter of the token0 fees goes to the protocol, none of the token1 fees
+	fees0 := new(ui.Int).Add(p.LPFees0, p.ProtocolFees0)
+	if !fees0.Eq(withoutProtocol.LPFees0) {
+		t.Errorf("fees %v + %v, want %v", p.LPFees0, p.ProtocolFees0, withoutProtocol.LPFees0)
+	}
+	// The share is rounded down in every step of the swap
+	quarter := new(ui.Int).Div(fees0, ui.NewInt(4))
+	if diff := new(ui.Int).Sub(quarter, p.ProtocolFees0); p.ProtocolFees0.Gt(quarter) || diff.Gt(ui.NewInt(2)) {
+		t.Errorf("protocol fees %v, want %v", p.ProtocolFees0, quarter)
+	}
+	if !p.ProtocolFees1.IsZero() || !p.LPFees1.Eq(withoutProtocol.LPFees1) {
+		t.Errorf("token1 protocol fees %v", p.ProtocolFees1)
+	}
+	if !p.SqrtRatioX96.Eq(withoutProtocol.SqrtRatioX96) {
+		t.Error("the protocol fee changed the price")
+	}
+
+	protocolFees0 := p.ProtocolFees0.Clone()
+	amount0, amount1 := p.CollectProtocol(ui.NewInt(math.MaxUint64), ui.NewInt(math.MaxUint64))
+	if want := new(ui.Int).Sub(protocolFees0, ui.NewInt(1)); !amount0.Eq(want) || !amount1.IsZero() {
+		t.Errorf("collected %v %v, want %v 0", amount0, amount1, want)
+	}
+	if !p.ProtocolFees0.Eq(ui.NewInt(1)) {
+		t.Errorf("%v protocol fees left, want 1", p.ProtocolFees0)
+	}
+}
+
+func TestOracle(t *testing.T) {
+	p := testPool(t)
+	if err := p.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	p.IncreaseObservationCardinalityNext(10)
+	start := p.TickCurrent
+	// The liquidity added at 0 initializes the oracle, the swap at 200 moves the tick
+	p.Timestamp = 100
+	if _, _, err := p.Mint("a", 192000, 194000, ui.NewInt(1_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	p.Timestamp = 200
+	if _, _, err := p.ExactInputSwap(ui.NewInt(100_000_000_000), p.Token0, ui.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if p.TickCurrent == start {
+		t.Fatal("the swap did not move the tick")
+	}
+	p.Timestamp = 300
+	tickCumulatives, _, err := p.Observe([]int{200, 100, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := tickCumulatives[1] - tickCumulatives[0]; d != int64(start)*100 {
+		t.Errorf("tick cumulative before the swap %d, want %d", d, start*100)
+	}
+	if d := tickCumulatives[2] - tickCumulatives[1]; d != int64(p.TickCurrent)*100 {
+		t.Errorf("tick cumulative after the swap %d, want %d", d, p.TickCurrent*100)
+	}
+	if _, _, err := p.Observe([]int{301}); err == nil {
+		t.Error("observe before the first observation")
+	}
+}
+
+func TestSwapAcrossNegativeWord(t *testing.T) {
+	// Tick -2000 with a tick spacing of 10, the swap ends in the word below -2560 at a tick that is not a multiple of 10
+	p, err := NewPool("A", "B", 500, tickmath.TM.GetSqrtRatioAtTick(-2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, _, err := p.ExactInputSwap(ui.NewInt(50_000_000_000_000), p.Token0, ui.NewInt(0))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the swap does not stop at the word boundary")
+	}
+	if p.TickCurrent >= -2560 || p.TickCurrent%10 == 0 {
+		t.Fatalf("tick %d, want a tick below -2560 that is not a multiple of 10", p.TickCurrent)
+	}
+	if tick, _ := tickmath.TM.GetTickAtSqrtRatio(p.SqrtRatioX96); tick != p.TickCurrent {
+		t.Errorf("tick %d, tick of the price %d", p.TickCurrent, tick)
+	}
+}
+
+func TestQuoteExactInputSwap(t *testing.T) {
+	p := testPool(t)
+	if err := p.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	key := PositionKey{"lp", 190000, 200000}
+	if _, _, err := p.Mint(key.Owner, key.TickLower, key.TickUpper, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	before := p.Clone()
+	// 15 ETH move the price above the range of the position
+	amount := ui.NewInt(15_000_000_000_000_000_000)
+
+	// Without positions the quote crosses their ticks, where the swap steps round separately
+	check := func(name string, swapped *Pool, units uint64, without ...PositionKey) {
+		amount0, amount1, sqrtPriceX96, err := p.QuoteExactInputSwap(amount, p.Token1, ui.NewInt(0), without...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want0, want1, err := swapped.ExactInputSwap(amount, swapped.Token1, ui.NewInt(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff0 := new(ui.Int).Sub(amount0, want0)
+		if diff0.Sign() < 0 {
+			diff0.Neg(diff0)
+		}
+		diffPrice := new(ui.Int).Sub(sqrtPriceX96, swapped.SqrtRatioX96)
+		if diffPrice.Sign() < 0 {
+			diffPrice.Neg(diffPrice)
+		}
+		if diff0.Gt(ui.NewInt(units)) || !amount1.Eq(want1) || diffPrice.Gt(new(ui.Int).Rsh(swapped.SqrtRatioX96, 48)) {
+			t.Errorf("%s: quoted %v %v at %v, swapped %v %v at %v", name, amount0.SToBig(), amount1, sqrtPriceX96, want0.SToBig(), want1, swapped.SqrtRatioX96)
+		}
+		if swapped.TickCurrent <= key.TickUpper {
+			t.Errorf("%s: the swap does not leave the range, tick %d", name, swapped.TickCurrent)
+		}
+	}
+	check("pool", p.Clone(), 0)
+	burnt := p.Clone()
+	if _, _, err := burnt.Burn(key.Owner, key.TickLower, key.TickUpper, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	check("without the position", burnt, 1, key)
+
+	if !p.SqrtRatioX96.Eq(before.SqrtRatioX96) || p.TickCurrent != before.TickCurrent || !p.Liquidity.Eq(before.Liquidity) ||
+		!p.FeeGrowthGlobal1X128.Eq(before.FeeGrowthGlobal1X128) || p.Actions != before.Actions {
+		t.Error("the quotes changed the pool")
+	}
+	if _, _, _, err := p.QuoteExactInputSwap(amount, p.Token1, ui.NewInt(0), PositionKey{"nobody", 190000, 200000}); !errors.Is(err, ErrPositionNotFound) {
+		t.Errorf("unknown position: %v", err)
+	}
+}
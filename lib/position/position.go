@@ -11,8 +11,10 @@ func (i *Info) Clone() *Info {
 		Liquidity:                i.Liquidity.Clone(),
 		FeeGrowthInside0LastX128: i.FeeGrowthInside0LastX128.Clone(),
 		FeeGrowthInside1LastX128: i.FeeGrowthInside1LastX128.Clone(),
-		TokensOwed0:              i.TokensOwed1.Clone(),
+		TokensOwed0:              i.TokensOwed0.Clone(),
 		TokensOwed1:              i.TokensOwed1.Clone(),
+		FeesEarned0:              i.FeesEarned0.Clone(),
+		FeesEarned1:              i.FeesEarned1.Clone(),
 	}
 }
 
@@ -22,6 +24,9 @@ type Info struct {
 	FeeGrowthInside1LastX128 *ui.Int
 	TokensOwed0              *ui.Int
 	TokensOwed1              *ui.Int
+	// FeesEarned are all fees ever credited to TokensOwed, principal from burns is not included
+	FeesEarned0 *ui.Int
+	FeesEarned1 *ui.Int
 }
 
 func NewPosition() *Info {
@@ -31,6 +36,8 @@ func NewPosition() *Info {
 		FeeGrowthInside1LastX128: ui.NewInt(0),
 		TokensOwed0:              ui.NewInt(0),
 		TokensOwed1:              ui.NewInt(0),
+		FeesEarned0:              ui.NewInt(0),
+		FeesEarned1:              ui.NewInt(0),
 	}
 }
 
@@ -45,4 +52,15 @@ func (i *Info) Update(LiquidityDelta, FeeGrowthInside0X128, FeeGrowthInside1X128
 	i.FeeGrowthInside1LastX128 = FeeGrowthInside1X128
 	i.TokensOwed0.Add(i.TokensOwed0, TokensOwed0)
 	i.TokensOwed1.Add(i.TokensOwed1, TokensOwed1)
+	i.FeesEarned0.Add(i.FeesEarned0, TokensOwed0)
+	i.FeesEarned1.Add(i.FeesEarned1, TokensOwed1)
+}
+
+// UncollectedFees returns the owed tokens plus the fees accrued since the last Update without updating the position
+func (i *Info) UncollectedFees(FeeGrowthInside0X128, FeeGrowthInside1X128 *ui.Int) (*ui.Int, *ui.Int) {
+	temp0 := new(ui.Int).Sub(FeeGrowthInside0X128, i.FeeGrowthInside0LastX128)
+	temp1 := new(ui.Int).Sub(FeeGrowthInside1X128, i.FeeGrowthInside1LastX128)
+	fees0 := fullmath.MulDiv(temp0, i.Liquidity, cons.Q128)
+	fees1 := fullmath.MulDiv(temp1, i.Liquidity, cons.Q128)
+	return fees0.Add(fees0, i.TokensOwed0), fees1.Add(fees1, i.TokensOwed1)
 }
@@ -1,11 +1,14 @@
 package prices
 
 import (
+	"errors"
 	"math/big"
-	"uniswap-simulator/lib/invariant"
 	ui "uniswap-simulator/uint256"
 )
 
+// ErrPriceSquareOverflow is returned by Add for a price too large to be squared and summed
+var ErrPriceSquareOverflow = errors.New("price square overflows")
+
 type Prices struct {
 	prices []*ui.Int
 	index  int
@@ -20,17 +23,20 @@ func NewPrices(len int) *Prices {
 	return &Prices{prices, 0, len}
 }
 
-func (p *Prices) Add(price *ui.Int) {
+func (p *Prices) Add(price *ui.Int) error {
 	//Square the price
 	// For USDC - ETH Pool this never overflows. Absolutely not guaranteed otherwise.
 	// Todo Fix this so it never overflows
-	priceSquareX192 := new(ui.Int).Mul(price, price)
+	priceSquareX192, overflow := new(ui.Int).MulOverflow(price, price)
 
 	shifted := new(ui.Int).Rsh(priceSquareX192, 230)
-	invariant.Invariant(shifted.IsZero(), "Price square should not overflow")
+	if overflow || !shifted.IsZero() {
+		return ErrPriceSquareOverflow
+	}
 
 	p.prices[p.index] = priceSquareX192
 	p.index = (p.index + 1) % p.length
+	return nil
 }
 
 func (p *Prices) Average() *ui.Int {
@@ -51,16 +57,13 @@ func (p *Prices) Volatility() *ui.Int {
 		// IDK why its needed going to Square it later anyway
 		diff.Abs(diff)
 		diffBig := diff.ToBig()
-		invariant.Invariant(diffBig.Sign() >= 0, "Volatility: diffBig is not negative")
 		diff2 := new(big.Int).Mul(diffBig, diffBig)
 		sum.Add(sum, diff2)
 	}
 	nMinus1 := big.NewInt(int64(p.length - 1))
 	variance := new(big.Int).Div(sum, nMinus1)
-	invariant.Invariant(variance.Sign() >= 0, "Volatility: variance is not negative")
 	// X192 number
 	volatility := new(big.Int).Sqrt(variance)
-	invariant.Invariant(volatility.Sign() >= 0, "Volatility: volatility is not negative")
 	ret, _ := ui.FromBig(volatility)
 	return ret
 
@@ -0,0 +1,250 @@
+package result
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	ui "uniswap-simulator/uint256"
+)
+
+// NewRunResult calculates return and risk metrics from hourly snapshots of the USD amount
+func NewRunResult(amountUSDSnapshots []*ui.Int) RunResult {
+	pricesHourly := make([]*ui.Int, 0, len(amountUSDSnapshots))
+	pricesDaily := make([]*ui.Int, 0, len(amountUSDSnapshots)/24)
+	pricesWeekly := make([]*ui.Int, 0, len(amountUSDSnapshots)/24/7)
+	for i := 0; i < len(amountUSDSnapshots); i++ {
+		pricesHourly = append(pricesHourly, amountUSDSnapshots[i].Clone())
+		if i%24 == 0 {
+			pricesDaily = append(pricesDaily, amountUSDSnapshots[i].Clone())
+		}
+		if i%(24*7) == 0 {
+			pricesWeekly = append(pricesWeekly, amountUSDSnapshots[i].Clone())
+		}
+	}
+
+	length := len(amountUSDSnapshots)
+	amountUSDEnd := amountUSDSnapshots[length-1]
+	amountEnd := amountUSDEnd.ToBig().String()
+
+	amountEndFloat, _ := new(big.Float).SetInt(amountUSDEnd.ToBig()).Float64()
+	amountStartFloat, _ := new(big.Float).SetInt(amountUSDSnapshots[0].ToBig()).Float64()
+	amountDiff := amountEndFloat - amountStartFloat
+	// A run that starts without value has no return
+	roi := 0.0
+	if amountStartFloat > 0 {
+		roi = amountDiff / amountStartFloat
+	}
+
+	return RunResult{
+		StartAmount:             amountUSDSnapshots[0].ToBig().String(),
+		EndAmount:               amountEnd,
+		Return:                  roi,
+		StandardDeviationHourly: calculateStd(pricesHourly),
+		StandardDeviationDaily:  calculateStd(pricesDaily),
+		StandardDeviationWeekly: calculateStd(pricesWeekly),
+		DownwardDeviationHourly: calculateDownDeviation(pricesHourly),
+		DownwardDeviationDaily:  calculateDownDeviation(pricesDaily),
+		DownwardDeviationWeekly: calculateDownDeviation(pricesWeekly),
+		MaxDrawdown:             calculateMaximumDrawdown(pricesHourly),
+		VaR95Hourly:             calculateVar(pricesHourly),
+		VaR95Daily:              calculateVar(pricesDaily),
+		VaR95Weekly:             calculateVar(pricesWeekly),
+	}
+}
+
+// Attribution is what a run needs to split its return into fees and price exposure
+type Attribution struct {
+	StartAmount0 *ui.Int
+	StartAmount1 *ui.Int
+	Fees0        *ui.Int
+	Fees1        *ui.Int
+	// PoolFees are the fees paid to all liquidity of the pool in the run, the strategy's included
+	PoolFees0 *ui.Int
+	PoolFees1 *ui.Int
+	// GasCost1 is the gas paid in token1
+	GasCost1 *ui.Int
+	// SqrtPricesX96 at every amount snapshot
+	SqrtPricesX96 []*ui.Int
+	// Duration of the run in seconds
+	Duration int
+	// LiquidationUSD are the liquidation values of the amount snapshots, empty without the liquidation mode
+	LiquidationUSD []*ui.Int
+}
+
+const secondsPerYear = 365 * 24 * 60 * 60
+
+// Attribute adds the fee and baseline metrics to a result of NewRunResult
+func (r *RunResult) Attribute(a Attribution) {
+	if a.StartAmount0 == nil || len(a.SqrtPricesX96) == 0 {
+		return
+	}
+	prices := make([]float64, len(a.SqrtPricesX96))
+	for i, sqrtPriceX96 := range a.SqrtPricesX96 {
+		prices[i] = price1(sqrtPriceX96)
+	}
+	startPrice, endPrice := prices[0], prices[len(prices)-1]
+	startValue := toFloat(a.StartAmount0) + toFloat(a.StartAmount1)*startPrice
+	endValue, _ := new(big.Float).SetString(r.EndAmount)
+	endValueFloat, _ := endValue.Float64()
+	fees := toFloat(a.Fees0) + toFloat(a.Fees1)*endPrice
+	hodl := toFloat(a.StartAmount0) + toFloat(a.StartAmount1)*endPrice
+	gasCost := 0.0
+	if a.GasCost1 != nil {
+		gasCost = toFloat(a.GasCost1) * endPrice
+	}
+
+	rebalanced := startValue
+	for i := 1; i < len(prices); i++ {
+		rebalanced *= 0.5 + 0.5*prices[i]/prices[i-1]
+	}
+
+	r.GasCostUSD = strconv.FormatFloat(math.Ceil(gasCost), 'f', 0, 64)
+	r.FeesUSD = strconv.FormatFloat(math.Floor(fees), 'f', 0, 64)
+	if startValue > 0 {
+		r.FeeReturn = fees / startValue
+		r.HODLReturn = hodl/startValue - 1
+		r.RebalancedReturn = rebalanced/startValue - 1
+	}
+	if a.PoolFees0 != nil {
+		if poolFees := toFloat(a.PoolFees0) + toFloat(a.PoolFees1)*endPrice; poolFees > 0 {
+			r.FeeShare = fees / poolFees
+		}
+	}
+	if a.Duration > 0 {
+		r.FeeAPR = r.FeeReturn * secondsPerYear / float64(a.Duration)
+	}
+	if hodl > 0 {
+		r.ImpermanentLoss = (endValueFloat-fees+gasCost)/hodl - 1
+		r.ReturnVsHODL = endValueFloat/hodl - 1
+	}
+	if rebalanced > 0 {
+		r.ReturnVsRebalanced = endValueFloat/rebalanced - 1
+	}
+	if n := len(a.LiquidationUSD); n > 0 {
+		liquidationEnd := toFloat(a.LiquidationUSD[n-1])
+		if startValue > 0 {
+			r.LiquidationReturn = liquidationEnd/startValue - 1
+		}
+		if endValueFloat > 0 {
+			r.LiquidationCost = 1 - liquidationEnd/endValueFloat
+		}
+	}
+}
+
+// price1 returns the price of token1 in token0, 2^192 / sqrtPriceX96^2
+func price1(sqrtPriceX96 *ui.Int) float64 {
+	sqrtPrice := new(big.Float).SetInt(sqrtPriceX96.ToBig())
+	sqrtPrice.SetMantExp(sqrtPrice, -96)
+	price, _ := new(big.Float).Quo(big.NewFloat(1), new(big.Float).Mul(sqrtPrice, sqrtPrice)).Float64()
+	return price
+}
+
+func toFloat(x *ui.Int) float64 {
+	f, _ := new(big.Float).SetInt(x.ToBig()).Float64()
+	return f
+}
+
+func getReturns(prices []*ui.Int) []float64 {
+	returns := make([]float64, 0, len(prices))
+	for i := 1; i < len(prices); i++ {
+		sIMinus1 := prices[i-1].ToBig()
+		sI := prices[i].ToBig()
+		sIMinus1F, _ := new(big.Float).SetInt(sIMinus1).Float64()
+		sIF, _ := new(big.Float).SetInt(sI).Float64()
+		// The log return is undefined once gas used up the whole amount
+		if sIMinus1F == 0 || sIF == 0 {
+			continue
+		}
+		ret := math.Log(sIF / sIMinus1F)
+		returns = append(returns, ret)
+	}
+	return returns
+}
+
+func calculateMaximumDrawdown(prices []*ui.Int) float64 {
+	if len(prices) <= 1 {
+		return 0
+	}
+	pricesFloat := make([]float64, 0, len(prices))
+	for _, price := range prices {
+		floatNumber, _ := new(big.Float).SetInt(price.ToBig()).Float64()
+		pricesFloat = append(pricesFloat, floatNumber)
+	}
+	maxPrice := pricesFloat[0]
+	maxDrawdown := 0.0
+	for _, price := range pricesFloat {
+		maxPrice = math.Max(maxPrice, price)
+		if maxPrice == 0 {
+			continue
+		}
+		drawdown := (price - maxPrice) / maxPrice
+		maxDrawdown = math.Min(maxDrawdown, drawdown)
+	}
+	return maxDrawdown
+}
+
+// MAR is Zero
+func calculateDownDeviation(prices []*ui.Int) float64 {
+	if len(prices) <= 2 {
+		return 0
+	}
+	returns := getReturns(prices)
+	if len(returns) < 2 {
+		return 0
+	}
+	sum := 0.0
+	for _, r := range returns {
+		if r < 0 {
+			sum += r * r
+		}
+	}
+	n := len(returns)
+	sum = sum / float64(n)
+	downwardDeviation := math.Sqrt(sum)
+	return downwardDeviation
+
+}
+
+// VaR 95%
+func calculateVar(prices []*ui.Int) float64 {
+	if len(prices) <= 2 {
+		return 0
+	}
+	returns := getReturns(prices)
+	if len(returns) < 2 {
+		return 0
+	}
+	sort.Float64s(returns)
+	idx := len(returns) / 20
+	var95 := returns[idx]
+	return var95
+}
+
+func calculateStd(prices []*ui.Int) float64 {
+	if len(prices) <= 2 {
+		return 0
+	}
+	returns := getReturns(prices)
+	if len(returns) < 2 {
+		return 0
+	}
+	n := len(returns)
+	sum1 := 0.0
+	for _, r := range returns {
+		sum1 += r * r
+	}
+	sum1 = sum1 / float64(n-1)
+	sum2 := 0.0
+	for _, r := range returns {
+		sum2 += r
+	}
+	sum2 = sum2 * sum2
+	quotient := n * (n - 1)
+	sum2 = sum2 / float64(quotient)
+
+	variance := sum1 - sum2
+	standardDeviation := math.Sqrt(variance)
+
+	return standardDeviation
+}
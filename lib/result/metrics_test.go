@@ -0,0 +1,75 @@
+package result
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestAttribute(t *testing.T) {
+	// Price of token1 in token0 is 1 at the start and 4 at the end
+	priceOne := new(ui.Int).Lsh(ui.NewInt(1), 96)
+	priceFour := new(ui.Int).Rsh(priceOne, 1)
+	r := RunResult{EndAmount: "3300"}
+	r.Attribute(Attribution{
+		StartAmount0:  ui.NewInt(500),
+		StartAmount1:  ui.NewInt(500),
+		Fees0:         ui.NewInt(100),
+		Fees1:         ui.NewInt(25),
+		SqrtPricesX96: []*ui.Int{priceOne, priceFour},
+		Duration:      secondsPerYear / 2,
+	})
+	near := func(name string, got, want float64) {
+		if math.Abs(got-want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	// Fees are 100 + 25*4 = 200 on a start value of 1000
+	if r.FeesUSD != "200" {
+		t.Errorf("FeesUSD = %s, want 200", r.FeesUSD)
+	}
+	near("FeeReturn", r.FeeReturn, 0.2)
+	near("FeeAPR", r.FeeAPR, 0.4)
+	// Holding is worth 500 + 500*4 = 2500
+	near("HODLReturn", r.HODLReturn, 1.5)
+	near("ImpermanentLoss", r.ImpermanentLoss, 3100.0/2500-1)
+	near("ReturnVsHODL", r.ReturnVsHODL, 3300.0/2500-1)
+	// 50/50 rebalanced: 1000 * (0.5 + 0.5*4) = 2500
+	near("RebalancedReturn", r.RebalancedReturn, 1.5)
+	near("ReturnVsRebalanced", r.ReturnVsRebalanced, 3300.0/2500-1)
+}
+
+func TestZeroAmounts(t *testing.T) {
+	// A point without amounts has no value at any snapshot, its metrics must still encode as JSON
+	priceOne := new(ui.Int).Lsh(ui.NewInt(1), 96)
+	snapshots := make([]*ui.Int, 48)
+	for i := range snapshots {
+		snapshots[i] = ui.NewInt(0)
+	}
+	r := NewRunResult(snapshots)
+	r.Attribute(Attribution{
+		StartAmount0:   ui.NewInt(0),
+		StartAmount1:   ui.NewInt(0),
+		Fees0:          ui.NewInt(0),
+		Fees1:          ui.NewInt(0),
+		PoolFees0:      ui.NewInt(0),
+		PoolFees1:      ui.NewInt(0),
+		SqrtPricesX96:  []*ui.Int{priceOne, priceOne},
+		Duration:       secondsPerYear,
+		LiquidationUSD: []*ui.Int{ui.NewInt(0), ui.NewInt(0)},
+	})
+	if _, err := json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]float64{
+		"Return": r.Return, "MaxDrawdown": r.MaxDrawdown, "FeeReturn": r.FeeReturn, "FeeAPR": r.FeeAPR,
+		"HODLReturn": r.HODLReturn, "ImpermanentLoss": r.ImpermanentLoss, "ReturnVsHODL": r.ReturnVsHODL,
+		"RebalancedReturn": r.RebalancedReturn, "ReturnVsRebalanced": r.ReturnVsRebalanced,
+		"LiquidationReturn": r.LiquidationReturn, "LiquidationCost": r.LiquidationCost,
+	} {
+		if value != 0 {
+			t.Errorf("%s = %v, want 0", name, value)
+		}
+	}
+}
@@ -5,8 +5,32 @@ type Snapshot struct {
 	Amount0   string `json:"amount0"`
 	Amount1   string `json:"amount1"`
 	AmountUSD string `json:"amountUSD"`
-	Price     string `json:"price"`
+	// Price of token0 in token1
+	Price     string             `json:"price"`
+	Tick      int                `json:"tick"`
+	Positions []PositionSnapshot `json:"positions"`
+	// LiquidationUSD is the value if amount1 was sold in the pool, only in the liquidation mode
+	LiquidationUSD string `json:"liquidationUSD,omitempty"`
 }
+
+type PositionSnapshot struct {
+	// Pool is the index of the pool for multi pool runs
+	Pool             int    `json:"pool"`
+	TickLower        int    `json:"tick_lower"`
+	TickUpper        int    `json:"tick_upper"`
+	Liquidity        string `json:"liquidity"`
+	InRange          bool   `json:"in_range"`
+	UncollectedFees0 string `json:"uncollected_fees0"`
+	UncollectedFees1 string `json:"uncollected_fees1"`
+}
+
+// Series is the snapshot time series of a single run
+type Series struct {
+	Parameters     map[string]float64 `json:"parameters"`
+	UpdateInterval int                `json:"update_interval"`
+	Snapshots      []Snapshot         `json:"snapshots"`
+}
+
 type Save struct {
 	StartAmount string      `json:"start_amount"`
 	StartTime   int         `json:"start_time"`
@@ -16,20 +40,59 @@ type Save struct {
 
 type RunResult struct {
 	UpdateInterval int `json:"update_interval"`
-	//ParameterA     int `json:"parameterA"`
-	//ParameterB     int `json:"parameterB"`
-	MultiplierX10           int     `json:"multiplierX10"`
-	HistoryWindow           int     `json:"history_window"`
-	Return                  float64 `json:"return_on_investment"`
-	EndAmount               string  `json:"end_amount"`
-	MaxDrawdown             float64 `json:"max_draw_down"`
-	StandardDeviationHourly float64 `json:"standard_deviation_hourly"`
-	StandardDeviationDaily  float64 `json:"standard_deviation_daily"`
-	StandardDeviationWeekly float64 `json:"standard_deviation_weekly"`
-	DownwardDeviationHourly float64 `json:"downward_deviation_hourly"`
-	DownwardDeviationDaily  float64 `json:"downward_deviation_daily"`
-	DownwardDeviationWeekly float64 `json:"downward_deviation_weekly"`
-	VaR95Hourly             float64 `json:"VaR95_hourly"`
-	VaR95Daily              float64 `json:"VaR95_daily"`
-	VaR95Weekly             float64 `json:"VaR95_weekly"`
+	// MultiplierX10 is the multiplier parameter of the strategies that have one, like before Parameters
+	MultiplierX10 int `json:"multiplierX10"`
+	// Parameters of the strategy and the swept execution settings by name
+	Parameters              map[string]float64 `json:"parameters"`
+	HistoryWindow           int                `json:"history_window"`
+	Return                  float64            `json:"return_on_investment"`
+	StartAmount             string             `json:"start_amount"`
+	EndAmount               string             `json:"end_amount"`
+	MaxDrawdown             float64            `json:"max_draw_down"`
+	StandardDeviationHourly float64            `json:"standard_deviation_hourly"`
+	StandardDeviationDaily  float64            `json:"standard_deviation_daily"`
+	StandardDeviationWeekly float64            `json:"standard_deviation_weekly"`
+	DownwardDeviationHourly float64            `json:"downward_deviation_hourly"`
+	DownwardDeviationDaily  float64            `json:"downward_deviation_daily"`
+	DownwardDeviationWeekly float64            `json:"downward_deviation_weekly"`
+	VaR95Hourly             float64            `json:"VaR95_hourly"`
+	VaR95Daily              float64            `json:"VaR95_daily"`
+	VaR95Weekly             float64            `json:"VaR95_weekly"`
+	// Fees earned valued in token0 at the end price
+	FeesUSD   string  `json:"fees_usd"`
+	FeeReturn float64 `json:"fee_return"`
+	FeeAPR    float64 `json:"fee_apr"`
+	// FeeShare is the part of the fees paid to the liquidity of the pool the strategy earned
+	FeeShare float64 `json:"fee_share"`
+	// HODLReturn is the return of holding the start amounts, the NoProvisionStrategy baseline
+	HODLReturn float64 `json:"hodl_return"`
+	// ImpermanentLoss is the end value without fees and gas relative to holding the start amounts
+	ImpermanentLoss float64 `json:"impermanent_loss"`
+	ReturnVsHODL    float64 `json:"return_vs_hodl"`
+	// RebalancedReturn is the return of a portfolio rebalanced to 50/50 at every snapshot
+	RebalancedReturn   float64 `json:"rebalanced_return"`
+	ReturnVsRebalanced float64 `json:"return_vs_rebalanced"`
+	// Gas spent on strategy actions, the cost in token1 and valued in token0 at the end price
+	GasUnits   int    `json:"gas_units"`
+	GasCost    string `json:"gas_cost"`
+	GasCostUSD string `json:"gas_cost_usd"`
+	// Range orders filled and their average execution price relative to the pool price at the fill
+	LimitOrderFills int     `json:"limit_order_fills"`
+	FillPriceVsMid  float64 `json:"fill_price_vs_mid"`
+	// LiquidationReturn is the return if the end amounts were sold in the pool instead of valued at the spot price,
+	// LiquidationCost the part of the end value the price impact of the sale costs. Only in the liquidation mode.
+	LiquidationReturn float64 `json:"liquidation_return"`
+	LiquidationCost   float64 `json:"liquidation_cost"`
+	// Divergences are the replayed swaps that ended at another price or tick than recorded
+	Divergences int `json:"divergences"`
+	// Owner of the strategy in a shared replay
+	Owner string `json:"owner,omitempty"`
+	// Error of a failed run, whose metrics are zero
+	Error string `json:"error,omitempty"`
+}
+
+// SetParameters sets the parameters of the run and the multiplierX10 column they contain
+func (r *RunResult) SetParameters(parameters map[string]float64) {
+	r.Parameters = parameters
+	r.MultiplierX10 = int(parameters["multiplier"])
 }
@@ -0,0 +1,229 @@
+package result
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of the result writers
+const (
+	JSON     = "json"
+	JSONL    = "jsonl"
+	CSV      = "csv"
+	Columnar = "columnar"
+)
+
+// ParameterPrefix is prepended to the parameter names in the column schema
+const ParameterPrefix = "param_"
+
+// Writer writes a Save in one file format
+type Writer interface {
+	// Extension of the files written, including the dot
+	Extension() string
+	Write(w io.Writer, save Save) error
+}
+
+func NewWriter(format string) (Writer, error) {
+	switch format {
+	case JSON, "":
+		return jsonWriter{}, nil
+	case JSONL:
+		return jsonlWriter{}, nil
+	case CSV:
+		return csvWriter{}, nil
+	case Columnar:
+		return columnarWriter{}, nil
+	}
+	return nil, fmt.Errorf("unknown result format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+}
+
+func Formats() []string {
+	return []string{JSON, JSONL, CSV, Columnar}
+}
+
+// jsonWriter writes the whole Save as one indented document
+type jsonWriter struct{}
+
+func (jsonWriter) Extension() string { return ".json" }
+
+func (jsonWriter) Write(w io.Writer, save Save) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(save)
+}
+
+// jsonlWriter writes one RunResult per line
+type jsonlWriter struct{}
+
+func (jsonlWriter) Extension() string { return ".jsonl" }
+
+func (jsonlWriter) Write(w io.Writer, save Save) error {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for _, r := range save.Results {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// csvWriter writes a header with Columns and one row per RunResult.
+// Parameters a run does not have are left empty.
+type csvWriter struct{}
+
+func (csvWriter) Extension() string { return ".csv" }
+
+func (csvWriter) Write(w io.Writer, save Save) error {
+	columns := Columns(save.Results)
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, r := range save.Results {
+		for i, value := range row(r, columns) {
+			record[i] = formatValue(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// columnarWriter writes a JSON document with one array per column in the order of Columns.
+// Parameters a run does not have are null.
+type columnarWriter struct{}
+
+func (columnarWriter) Extension() string { return ".columns.json" }
+
+func (columnarWriter) Write(w io.Writer, save Save) error {
+	columns := Columns(save.Results)
+	data := make([][]interface{}, len(columns))
+	for i := range data {
+		data[i] = make([]interface{}, len(save.Results))
+	}
+	for j, r := range save.Results {
+		for i, value := range row(r, columns) {
+			data[i][j] = value
+		}
+	}
+
+	writer := bufio.NewWriter(w)
+	header, err := json.Marshal(struct {
+		StartAmount string   `json:"start_amount"`
+		StartTime   int      `json:"start_time"`
+		EndTime     int      `json:"end_time"`
+		Rows        int      `json:"rows"`
+		Columns     []string `json:"columns"`
+	}{save.StartAmount, save.StartTime, save.EndTime, len(save.Results), columns})
+	if err != nil {
+		return err
+	}
+	// Append the data object to the header so the columns keep their order
+	writer.Write(header[:len(header)-1])
+	writer.WriteString(`,"data":{`)
+	for i, column := range columns {
+		if i > 0 {
+			writer.WriteByte(',')
+		}
+		name, _ := json.Marshal(column)
+		values, err := json.Marshal(data[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+		writer.Write(name)
+		writer.WriteByte(':')
+		writer.Write(values)
+	}
+	writer.WriteString("}}\n")
+	return writer.Flush()
+}
+
+type field struct {
+	name  string
+	index int
+}
+
+// fields are the scalar fields of RunResult by json name in declaration order
+var fields = func() []field {
+	var fields []field
+	t := reflect.TypeOf(RunResult{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Float64, reflect.String, reflect.Bool:
+			fields = append(fields, field{name, i})
+		}
+	}
+	return fields
+}()
+
+// Columns is the column schema of results:
+// the fields of RunResult by json name in declaration order,
+// followed by ParameterPrefix and the name of every parameter of any run, sorted.
+func Columns(results []RunResult) []string {
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, f.name)
+	}
+	parameters := make(map[string]bool)
+	for _, r := range results {
+		for name := range r.Parameters {
+			parameters[name] = true
+		}
+	}
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		columns = append(columns, ParameterPrefix+name)
+	}
+	return columns
+}
+
+// row returns the values of r for columns. Missing parameters are nil.
+func row(r RunResult, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	v := reflect.ValueOf(r)
+	for i, f := range fields {
+		values[i] = v.Field(f.index).Interface()
+	}
+	for i := len(fields); i < len(columns); i++ {
+		if value, ok := r.Parameters[strings.TrimPrefix(columns[i], ParameterPrefix)]; ok {
+			values[i] = value
+		}
+	}
+	return values
+}
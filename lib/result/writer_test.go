@@ -0,0 +1,115 @@
+package result
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testSave() Save {
+	return Save{
+		StartAmount: "2000000",
+		StartTime:   1,
+		EndTime:     2,
+		Results: []RunResult{
+			{UpdateInterval: 3600, MultiplierX10: 1024, Parameters: map[string]float64{"multiplier": 1024, "snapshots": 100}, Return: 0.5, EndAmount: "3000000"},
+			{UpdateInterval: 7200, Parameters: map[string]float64{"a": 3}, Return: -0.25, EndAmount: "1500000"},
+		},
+	}
+}
+
+func TestColumns(t *testing.T) {
+	columns := Columns(testSave().Results)
+	if columns[0] != "update_interval" || columns[1] != "multiplierX10" || columns[2] != "history_window" {
+		t.Errorf("columns start with %v", columns[:3])
+	}
+	params := columns[len(columns)-3:]
+	if strings.Join(params, ",") != "param_a,param_multiplier,param_snapshots" {
+		t.Errorf("parameter columns %v", params)
+	}
+	for _, column := range columns {
+		if column == "parameters" {
+			t.Error("parameters is not a scalar column")
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	writer, _ := NewWriter(CSV)
+	var buf bytes.Buffer
+	if err := writer.Write(&buf, testSave()); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header and 2 rows", len(records))
+	}
+	get := func(row int, column string) string {
+		for i, name := range records[0] {
+			if name == column {
+				return records[row][i]
+			}
+		}
+		t.Fatalf("no column %s", column)
+		return ""
+	}
+	if get(1, "return_on_investment") != "0.5" || get(2, "end_amount") != "1500000" {
+		t.Errorf("rows %v", records[1:])
+	}
+	if get(1, "param_multiplier") != "1024" || get(2, "param_multiplier") != "" || get(2, "param_a") != "3" {
+		t.Errorf("parameter values %v", records[1:])
+	}
+	if get(1, "multiplierX10") != "1024" || get(2, "multiplierX10") != "0" {
+		t.Errorf("multiplierX10 %v", records[1:])
+	}
+}
+
+func TestSetParameters(t *testing.T) {
+	var r RunResult
+	r.SetParameters(map[string]float64{"multiplier": 2048, "snapshots": 100})
+	if r.MultiplierX10 != 2048 || r.Parameters["snapshots"] != 100 {
+		t.Errorf("result %+v", r)
+	}
+	r.SetParameters(map[string]float64{"interval_width": 1000})
+	if r.MultiplierX10 != 0 {
+		t.Errorf("multiplierX10 %d without a multiplier", r.MultiplierX10)
+	}
+}
+
+func TestColumnarWriter(t *testing.T) {
+	writer, _ := NewWriter(Columnar)
+	var buf bytes.Buffer
+	if err := writer.Write(&buf, testSave()); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Rows    int                      `json:"rows"`
+		Columns []string                 `json:"columns"`
+		Data    map[string][]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Rows != 2 || len(doc.Data) != len(doc.Columns) {
+		t.Fatalf("rows %d, %d columns, %d data", doc.Rows, len(doc.Columns), len(doc.Data))
+	}
+	if doc.Data["param_snapshots"][0] != 100.0 || doc.Data["param_snapshots"][1] != nil {
+		t.Errorf("param_snapshots %v", doc.Data["param_snapshots"])
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	for _, format := range Formats() {
+		if _, err := NewWriter(format); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := NewWriter("parquet"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
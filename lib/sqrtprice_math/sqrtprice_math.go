@@ -1,12 +1,17 @@
 package sqrtprice_math
 
 import (
+	"errors"
 	cons "uniswap-simulator/lib/constants"
 	fm "uniswap-simulator/lib/fullmath"
-	"uniswap-simulator/lib/invariant"
 	ui "uniswap-simulator/uint256"
 )
 
+var (
+	ErrSqrtPriceNotPositive = errors.New("sqrtPX96 must be positive")
+	ErrLiquidityNotPositive = errors.New("liquidity must be positive")
+)
+
 var MaxUint160 = new(ui.Int).Sub(new(ui.Int).Exp(ui.NewInt(2), ui.NewInt(160)), cons.One)
 
 func multiplyIn256(x, y *ui.Int) *ui.Int {
@@ -50,23 +55,35 @@ func GetAmount1Delta(sqrtRatioAX96, sqrtRatioBX96, liquidity *ui.Int, roundUp bo
 	return res
 }
 
-func GetNextSqrtPriceFromInput(sqrtPX96, liquidity, amountIn *ui.Int, zeroForOne bool) *ui.Int {
-	invariant.Invariant(sqrtPX96.Sign() == 1, "sqrtPX96 must be positive")
-	invariant.Invariant(liquidity.Sign() == 1, "liquidity must be positive")
+func GetNextSqrtPriceFromInput(sqrtPX96, liquidity, amountIn *ui.Int, zeroForOne bool) (*ui.Int, error) {
+	if err := checkPriceAndLiquidity(sqrtPX96, liquidity); err != nil {
+		return nil, err
+	}
 	if zeroForOne {
-		return getNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountIn, true)
+		return getNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountIn, true), nil
 	}
-	return getNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountIn, true)
+	return getNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountIn, true), nil
 }
 
-func GetNextSqrtPriceFromOutput(sqrtPX96, liquidity, amountOut *ui.Int, zeroForOne bool) *ui.Int {
-	invariant.Invariant(sqrtPX96.Sign() == 1, "sqrtPX96 must be positive")
-	invariant.Invariant(liquidity.Sign() == 1, "liquidity must be positive")
+func GetNextSqrtPriceFromOutput(sqrtPX96, liquidity, amountOut *ui.Int, zeroForOne bool) (*ui.Int, error) {
+	if err := checkPriceAndLiquidity(sqrtPX96, liquidity); err != nil {
+		return nil, err
+	}
 
 	if zeroForOne {
-		return getNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountOut, false)
+		return getNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountOut, false), nil
+	}
+	return getNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountOut, false), nil
+}
+
+func checkPriceAndLiquidity(sqrtPX96, liquidity *ui.Int) error {
+	if sqrtPX96.Sign() != 1 {
+		return ErrSqrtPriceNotPositive
+	}
+	if liquidity.Sign() != 1 {
+		return ErrLiquidityNotPositive
 	}
-	return getNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountOut, false)
+	return nil
 }
 
 func getNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amount *ui.Int, add bool) *ui.Int {
@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
@@ -14,6 +13,7 @@ And the remaining liquidity in a limit order [p, p + b] or [p - b, p]
 */
 
 type TwoIntervalAroundPriceStrategy struct {
+	Account
 	Amount0   *ui.Int
 	Amount1   *ui.Int
 	Pool      *pool.Pool
@@ -22,21 +22,41 @@ type TwoIntervalAroundPriceStrategy struct {
 	Positions []Position
 }
 
-func (s *TwoIntervalAroundPriceStrategy) MakeSnapshot() {
-	//TODO implement me
+func (s *TwoIntervalAroundPriceStrategy) MakeSnapshot() error {
+	return nil
 }
 
+func (s *TwoIntervalAroundPriceStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+// NewTwoIntervalAroundPriceStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewTwoIntervalAroundPriceStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, a, b int) *TwoIntervalAroundPriceStrategy {
 	return &TwoIntervalAroundPriceStrategy{
+		Account:   Account{DefaultOwner},
 		Amount0:   amount0.Clone(),
 		Amount1:   amount1.Clone(),
-		Pool:      pool.Clone(),
+		Pool:      pool,
 		a:         a,
 		b:         b,
 		Positions: make([]Position, 0),
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "two_interval_around_price",
+		Description: "[pc - a, pc + a] and the remainder in a limit order [pc, pc + b] or [pc - b, pc]",
+		Parameters: []Parameter{
+			{Name: "a", Type: Int, Default: 1000, Min: 1, Max: float64(tickmath.MaxTick), Description: "half width of the main interval in ticks"},
+			{Name: "b", Type: Int, Default: 100, Min: 1, Max: float64(tickmath.MaxTick), Description: "width of the limit order in ticks"},
+		},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewTwoIntervalAroundPriceStrategy(amount0, amount1, pool, params.Int("a"), params.Int("b"))
+		},
+	})
+}
+
 func (s *TwoIntervalAroundPriceStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -55,10 +75,16 @@ func (s *TwoIntervalAroundPriceStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
-func (s *TwoIntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int) {
+func (s *TwoIntervalAroundPriceStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+func (s *TwoIntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -66,56 +92,70 @@ func (s *TwoIntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int) {
 	return
 }
 
-func (s *TwoIntervalAroundPriceStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *TwoIntervalAroundPriceStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
 	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
 }
 
-func (s *TwoIntervalAroundPriceStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *TwoIntervalAroundPriceStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	// MainPosition
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.a, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.a, tickSpacing)
 
-	s.mintPosition(tickLower, tickUpper)
+	if err = s.mintPosition(tickLower, tickUpper); err != nil {
+		return
+	}
 
 	// SecondaryPosition
 	if !s.Amount0.IsZero() {
 		tickLower = tickmath.Ceil(s.Pool.TickCurrent, tickSpacing)
 		tickUpper = tickLower + s.b
-		s.mintPosition(tickLower, tickUpper)
+		if err = s.mintPosition(tickLower, tickUpper); err != nil {
+			return
+		}
 	}
 
 	if !s.Amount1.IsZero() {
 		tickUpper = tickmath.Floor(s.Pool.TickCurrent, tickSpacing)
 		tickLower = tickUpper - s.b
-		s.mintPosition(tickLower, tickUpper)
+		if err = s.mintPosition(tickLower, tickUpper); err != nil {
+			return
+		}
 	}
 
 	return
 }
 
-func (s *TwoIntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
+func (s *TwoIntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
 
 	// We are not interested in GasFee So just burn every time.
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -124,22 +164,28 @@ func (s *TwoIntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *
 	s.Positions = make([]Position, 0)
 
 	// MainPosition
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.a, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.a, tickSpacing)
-	s.mintPosition(tickLower, tickUpper)
+	if err = s.mintPosition(tickLower, tickUpper); err != nil {
+		return
+	}
 
 	// SecondaryPosition
 	if !s.Amount0.IsZero() {
 		tickLower = tickmath.Ceil(s.Pool.TickCurrent, tickSpacing)
 		tickUpper = tickLower + s.b
-		s.mintPosition(tickLower, tickUpper)
+		if err = s.mintPosition(tickLower, tickUpper); err != nil {
+			return
+		}
 	}
 
 	if !s.Amount1.IsZero() {
 		tickUpper = tickmath.Floor(s.Pool.TickCurrent, tickSpacing)
 		tickLower = tickUpper - s.b
-		s.mintPosition(tickLower, tickUpper)
+		if err = s.mintPosition(tickLower, tickUpper); err != nil {
+			return
+		}
 	}
 
 	return
@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
@@ -10,6 +9,7 @@ import (
 
 // ConstantIntervalStrategy [p-a, p+a]
 type ConstantIntervalStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -19,24 +19,45 @@ type ConstantIntervalStrategy struct {
 	Positions     []Position
 }
 
-func (s *ConstantIntervalStrategy) MakeSnapshot() {
-	//TODO implement me
+func (s *ConstantIntervalStrategy) MakeSnapshot() error {
+	return nil
 }
 
+func (s *ConstantIntervalStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+// NewConstantIntervalStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewConstantIntervalStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *ConstantIntervalStrategy {
 	return &ConstantIntervalStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     make([]Position, 0),
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "constant_interval",
+		Description: "[p - a, p + a] around the price at the start",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewConstantIntervalStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
 func (s *ConstantIntervalStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *ConstantIntervalStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
 func (s *ConstantIntervalStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -51,10 +72,12 @@ func (s *ConstantIntervalStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *ConstantIntervalStrategy) BurnAll() (amount0, amount1 *ui.Int) {
+func (s *ConstantIntervalStrategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -62,35 +85,42 @@ func (s *ConstantIntervalStrategy) BurnAll() (amount0, amount1 *ui.Int) {
 	return
 }
 
-func (s *ConstantIntervalStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *ConstantIntervalStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
 	// New Positions
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
 	s.tickLower, s.tickUpper = tickLower, tickUpper
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return
+	}
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return
+	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
 	return
 }
 
-func (s *ConstantIntervalStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
+func (s *ConstantIntervalStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
 
 	// Only runs ones
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -99,19 +129,25 @@ func (s *ConstantIntervalStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int
 	// New Positions
 	tickLower := s.tickLower
 	tickUpper := s.tickUpper
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return
+	}
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 
 	if amount.IsZero() {
 		return
 	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return
+	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
 	return
@@ -0,0 +1,188 @@
+package strategy
+
+import (
+	"math/big"
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/fullmath"
+	la "uniswap-simulator/lib/liquidity_amounts"
+	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+// weightPrecision is the denominator of the weights the amounts are split by
+const weightPrecision = 1_000_000
+
+// FeeTierStrategy [pc-a, pc+a] in every pool of the pair
+// Where pc is the current price of the pool.
+// Every update interval the capital is split across the pools by the fees per liquidity
+// they earned since the last update, equally at Init.
+type FeeTierStrategy struct {
+	Account
+	Amount0       *ui.Int
+	Amount1       *ui.Int
+	Pools         []*pool.Pool
+	IntervalWidth int // a in ticks
+	Positions     []Position
+	// Fee growth of the pools at the last allocation
+	feeGrowth0 []*ui.Int
+	feeGrowth1 []*ui.Int
+}
+
+// NewFeeTierStrategy changes pools, they are not cloned. Pass clones to keep using pools without the strategy.
+func NewFeeTierStrategy(amount0, amount1 *ui.Int, pools []*pool.Pool, intervalWidth int) *FeeTierStrategy {
+	return &FeeTierStrategy{
+		Account:       Account{DefaultOwner},
+		Amount0:       amount0.Clone(),
+		Amount1:       amount1.Clone(),
+		Pools:         pools,
+		IntervalWidth: intervalWidth,
+		Positions:     make([]Position, 0),
+	}
+}
+
+func init() {
+	Register(Definition{
+		Name:        "fee_tier",
+		Description: "[pc - a, pc + a] in every pool, split by the fees per liquidity of the last update interval",
+		Parameters:  []Parameter{intervalWidthParameter},
+		MultiPoolFactory: func(amount0, amount1 *ui.Int, pools []*pool.Pool, params Params) Strategy {
+			return NewFeeTierStrategy(amount0, amount1, pools, params.Int("interval_width"))
+		},
+	})
+}
+
+func (s *FeeTierStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *FeeTierStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+func (s *FeeTierStrategy) GetPool() *pool.Pool {
+	return s.Pools[0]
+}
+
+func (s *FeeTierStrategy) GetPools() []*pool.Pool {
+	return s.Pools
+}
+
+func (s *FeeTierStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+func (s *FeeTierStrategy) GetAmounts() (*ui.Int, *ui.Int) {
+	amount0, amount1 := new(ui.Int), new(ui.Int)
+	for _, position := range s.Positions {
+		sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickLower)
+		sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickUpper)
+		liquidityAmount0, liquidityAmount1 := la.GetAmountsForLiquidity(s.Pools[position.pool].SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, position.amount)
+		amount0.Add(amount0, liquidityAmount0)
+		amount1.Add(amount1, liquidityAmount1)
+	}
+	amount0.Add(amount0, s.Amount0)
+	amount1.Add(amount1, s.Amount1)
+	return amount0, amount1
+}
+
+func (s *FeeTierStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
+	for _, position := range s.Positions {
+		p := s.Pools[position.pool]
+		amount0, amount1, err := withdraw(p, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Amount0.Add(s.Amount0, amount0)
+		s.Amount1.Add(s.Amount1, amount1)
+	}
+	retamount0, retamount1 = s.Amount0.Clone(), s.Amount1.Clone()
+	s.Positions = make([]Position, 0)
+	return
+}
+
+// weights returns the share of every pool in weightPrecision, by the fees per liquidity since the last allocation
+// valued in token0
+func (s *FeeTierStrategy) weights() []uint64 {
+	yields := make([]*big.Int, len(s.Pools))
+	total := new(big.Int)
+	for i, p := range s.Pools {
+		yields[i] = new(big.Int)
+		if s.feeGrowth0 != nil {
+			growth0 := new(ui.Int).Sub(p.FeeGrowthGlobal0X128, s.feeGrowth0[i])
+			growth1 := new(ui.Int).Sub(p.FeeGrowthGlobal1X128, s.feeGrowth1[i])
+			priceSquareX192 := new(ui.Int).Mul(p.SqrtRatioX96, p.SqrtRatioX96)
+			growth1to0 := fullmath.MulDiv(growth1, cons.Q192, priceSquareX192)
+			yields[i].Add(growth0.ToBig(), growth1to0.ToBig())
+		}
+		total.Add(total, yields[i])
+	}
+
+	weights := make([]uint64, len(s.Pools))
+	for i := range weights {
+		if total.Sign() == 0 {
+			weights[i] = weightPrecision / uint64(len(s.Pools))
+			continue
+		}
+		weight := new(big.Int).Mul(yields[i], big.NewInt(weightPrecision))
+		weights[i] = weight.Div(weight, total).Uint64()
+	}
+	return weights
+}
+
+// allocate splits the idle amounts by the weights and mints an interval around the price in every pool
+func (s *FeeTierStrategy) allocate() error {
+	weights := s.weights()
+	rest0, rest1 := s.Amount0.Clone(), s.Amount1.Clone()
+	amounts0, amounts1 := make([]*ui.Int, len(s.Pools)), make([]*ui.Int, len(s.Pools))
+	for i := range s.Pools {
+		amounts0[i] = fullmath.MulDiv(s.Amount0, ui.NewInt(weights[i]), ui.NewInt(weightPrecision))
+		amounts1[i] = fullmath.MulDiv(s.Amount1, ui.NewInt(weights[i]), ui.NewInt(weightPrecision))
+		rest0.Sub(rest0, amounts0[i])
+		rest1.Sub(rest1, amounts1[i])
+	}
+	// The rounding rest stays idle
+	s.Amount0, s.Amount1 = rest0, rest1
+
+	for i, p := range s.Pools {
+		amount0, amount1 := amounts0[i], amounts1[i]
+		if amount0.IsZero() && amount1.IsZero() {
+			continue
+		}
+		tickLower := tickmath.Round(p.TickCurrent-s.IntervalWidth, p.TickSpacing)
+		tickUpper := tickmath.Round(p.TickCurrent+s.IntervalWidth, p.TickSpacing)
+		position, ok, err := SwapAndMint(p, s.Owner, amount0, amount1, tickLower, tickUpper)
+		if err != nil {
+			return err
+		}
+		if ok {
+			position.pool = i
+			s.Positions = append(s.Positions, position)
+		}
+		s.Amount0.Add(s.Amount0, amount0)
+		s.Amount1.Add(s.Amount1, amount1)
+	}
+
+	// After the swaps of the allocation, so their fees are not counted at the next one
+	s.feeGrowth0 = make([]*ui.Int, len(s.Pools))
+	s.feeGrowth1 = make([]*ui.Int, len(s.Pools))
+	for i, p := range s.Pools {
+		s.feeGrowth0[i] = p.FeeGrowthGlobal0X128.Clone()
+		s.feeGrowth1[i] = p.FeeGrowthGlobal1X128.Clone()
+	}
+	return nil
+}
+
+func (s *FeeTierStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
+	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
+	err = s.allocate()
+	return
+}
+
+func (s *FeeTierStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
+	err = s.allocate()
+	return
+}
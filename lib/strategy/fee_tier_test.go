@@ -0,0 +1,38 @@
+package strategy
+
+import (
+	"testing"
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/pool"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestFeeTierAllocation(t *testing.T) {
+	pool500 := testPool(t)
+	pool3000, err := pool.NewPool("USDC", "WETH", 3000, pool500.SqrtRatioX96.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool3000.AddLiquidity(-887220, 887220, ui.NewInt(100_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewFeeTierStrategy(ui.NewInt(10_000_000_000), ui.NewInt(3_000_000_000_000_000_000), []*pool.Pool{pool500, pool3000}, 1000)
+	if _, _, err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Positions) != 2 || s.Positions[0].Pool() != 0 || s.Positions[1].Pool() != 1 {
+		t.Fatalf("positions at Init %+v", s.Positions)
+	}
+
+	// Only the 3000 pool earns fees until the next allocation
+	if _, _, err := s.Pools[1].ExactInputSwap(ui.NewInt(1_000_000_000), s.Pools[1].Token0, cons.Zero); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Rebalance(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Positions) != 1 || s.Positions[0].Pool() != 1 {
+		t.Errorf("positions after Rebalance %+v", s.Positions)
+	}
+}
@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
@@ -9,6 +8,7 @@ import (
 )
 
 type FillUpStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -16,23 +16,45 @@ type FillUpStrategy struct {
 	Positions     []Position
 }
 
-func (s *FillUpStrategy) MakeSnapshot() {
+func (s *FillUpStrategy) MakeSnapshot() error {
+	return nil
+}
 
+func (s *FillUpStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
+
 func (s *FillUpStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *FillUpStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+// NewFillUpStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewFillUpStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *FillUpStrategy {
 	return &FillUpStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     make([]Position, 0),
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "fill_up",
+		Description: "[pc - a, pc + a], the remainder fills up the interval from the current price",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewFillUpStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
 func (s *FillUpStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -47,10 +69,12 @@ func (s *FillUpStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *FillUpStrategy) BurnAll() (retAmount0, retAmount1 *ui.Int) {
+func (s *FillUpStrategy) BurnAll() (retAmount0, retAmount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -59,56 +83,70 @@ func (s *FillUpStrategy) BurnAll() (retAmount0, retAmount1 *ui.Int) {
 	return
 }
 
-func (s *FillUpStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *FillUpStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
 	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
 }
 
-func (s *FillUpStrategy) setPositions() {
+func (s *FillUpStrategy) setPositions() (err error) {
 	// New Positions
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
 
-	s.mintPosition(tickLower, tickUpper)
+	if err = s.mintPosition(tickLower, tickUpper); err != nil {
+		return
+	}
 	// SecondaryPosition
 	if !s.Amount0.IsZero() {
 		tickLower = tickmath.Ceil(s.Pool.TickCurrent, tickSpacing)
 		if tickLower < tickUpper {
-			s.mintPosition(tickLower, tickUpper)
+			if err = s.mintPosition(tickLower, tickUpper); err != nil {
+				return
+			}
 		}
 	} else if !s.Amount1.IsZero() {
 		tickUpper = tickmath.Floor(s.Pool.TickCurrent, tickSpacing)
 		if tickLower < tickUpper {
-			s.mintPosition(tickLower, tickUpper)
+			if err = s.mintPosition(tickLower, tickUpper); err != nil {
+				return
+			}
 		}
 	}
-
+	return
 }
 
-func (s *FillUpStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *FillUpStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
-	s.setPositions()
+	err = s.setPositions()
 	return
 }
 
-func (s *FillUpStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
-	currAmount0, currAmount1 = s.BurnAll()
-	s.setPositions()
+func (s *FillUpStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
+	err = s.setPositions()
 	return
 }
@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/prices"
@@ -13,6 +12,7 @@ import (
 // Where pa is the average of the last n values of the price
 // And a is a parameter
 type IntervalAroundAverageStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -21,26 +21,46 @@ type IntervalAroundAverageStrategy struct {
 	PriceHistory  *prices.Prices
 }
 
+// NewIntervalAroundAverageStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewIntervalAroundAverageStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth, amountAverageSnapshots int) *IntervalAroundAverageStrategy {
 	priceHistory := prices.NewPrices(amountAverageSnapshots)
 	return &IntervalAroundAverageStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     make([]Position, 0),
 		PriceHistory:  priceHistory,
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "interval_around_average",
+		Description: "[pa - a, pa + a] around the average price",
+		Parameters:  []Parameter{intervalWidthParameter, snapshotsParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewIntervalAroundAverageStrategy(amount0, amount1, pool, params.Int("interval_width"), params.Int("snapshots"))
+		},
+	})
+}
+
 func (s *IntervalAroundAverageStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
-func (s *IntervalAroundAverageStrategy) MakeSnapshot() {
+func (s *IntervalAroundAverageStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+func (s *IntervalAroundAverageStrategy) MakeSnapshot() error {
 	sqrtPriceX96 := s.Pool.SqrtRatioX96
-	s.PriceHistory.Add(sqrtPriceX96)
+	return s.PriceHistory.Add(sqrtPriceX96)
+}
 
+func (s *IntervalAroundAverageStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
 func (s *IntervalAroundAverageStrategy) GetAmounts() (*ui.Int, *ui.Int) {
@@ -57,10 +77,12 @@ func (s *IntervalAroundAverageStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *IntervalAroundAverageStrategy) BurnAll() (retamount0, retamount1 *ui.Int) {
+func (s *IntervalAroundAverageStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -69,47 +91,64 @@ func (s *IntervalAroundAverageStrategy) BurnAll() (retamount0, retamount1 *ui.In
 	return
 }
 
-func (s *IntervalAroundAverageStrategy) getTicks() (tickLower, tickUpper int) {
+func (s *IntervalAroundAverageStrategy) getTicks() (tickLower, tickUpper int, err error) {
 	priceX192 := s.PriceHistory.Average()
 	sqrtPriceX96 := new(ui.Int).Sqrt(priceX192)
-	tick := tickmath.TM.GetTickAtSqrtRatio(sqrtPriceX96)
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tick, err := tickmath.TM.GetTickAtSqrtRatio(sqrtPriceX96)
+	if err != nil {
+		return
+	}
+	tickSpacing := s.Pool.TickSpacing
 	tickLower = tickmath.Round(tick-s.IntervalWidth, tickSpacing)
 	tickUpper = tickmath.Round(tick+s.IntervalWidth, tickSpacing)
 	return
 }
 
-func (s *IntervalAroundAverageStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *IntervalAroundAverageStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
 	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
 }
 
-func (s *IntervalAroundAverageStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *IntervalAroundAverageStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
-	tickLower, tickUpper := s.getTicks()
-	s.mintPosition(tickLower, tickUpper)
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
 
-func (s *IntervalAroundAverageStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
-	currAmount0, currAmount1 = s.BurnAll()
+func (s *IntervalAroundAverageStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
 
-	tickLower, tickUpper := s.getTicks()
-	s.mintPosition(tickLower, tickUpper)
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
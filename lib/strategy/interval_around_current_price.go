@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
@@ -12,6 +11,7 @@ import (
 // Where pc is the current price
 
 type IntervalAroundPriceStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -19,14 +19,22 @@ type IntervalAroundPriceStrategy struct {
 	Positions     []Position
 }
 
-func (s *IntervalAroundPriceStrategy) MakeSnapshot() {
-	//TODO implement me
+func (s *IntervalAroundPriceStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *IntervalAroundPriceStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
 func (s *IntervalAroundPriceStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *IntervalAroundPriceStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
 func (s *IntervalAroundPriceStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -41,20 +49,35 @@ func (s *IntervalAroundPriceStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
+// NewIntervalAroundPriceStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewIntervalAroundPriceStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *IntervalAroundPriceStrategy {
 	return &IntervalAroundPriceStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     make([]Position, 0),
 	}
 }
 
-func (s *IntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int) {
+func init() {
+	Register(Definition{
+		Name:        "interval_around_price",
+		Description: "[pc - a, pc + a] around the current price",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewIntervalAroundPriceStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
+func (s *IntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -62,36 +85,43 @@ func (s *IntervalAroundPriceStrategy) BurnAll() (amount0, amount1 *ui.Int) {
 	return
 }
 
-func (s *IntervalAroundPriceStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *IntervalAroundPriceStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
 	// New Positions
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
 
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return
+	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
 	return
 }
 
-func (s *IntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
+func (s *IntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
 
 	// We are not interested in GasFee So just burn every time.
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -99,24 +129,30 @@ func (s *IntervalAroundPriceStrategy) Rebalance() (currAmount0, currAmount1 *ui.
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	s.Positions = make([]Position, 0)
 
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
 
 	// New Positions
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
 		return
 	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return
+	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
 	return
@@ -0,0 +1,177 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	la "uniswap-simulator/lib/liquidity_amounts"
+	"uniswap-simulator/lib/oracle"
+	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+// IntervalAroundTWAPStrategy [twap - a, twap + a]
+// Where twap is the time weighted average tick the oracle of the pool returns for the last window seconds
+// And a is a parameter
+type IntervalAroundTWAPStrategy struct {
+	Account
+	Amount0       *ui.Int
+	Amount1       *ui.Int
+	Pool          *pool.Pool
+	IntervalWidth int // a in ticks
+	Window        int // in seconds
+	Positions     []Position
+}
+
+// NewIntervalAroundTWAPStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
+func NewIntervalAroundTWAPStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth, window, cardinality int) *IntervalAroundTWAPStrategy {
+	pool.IncreaseObservationCardinalityNext(cardinality)
+	return &IntervalAroundTWAPStrategy{
+		Account:       Account{DefaultOwner},
+		Amount0:       amount0.Clone(),
+		Amount1:       amount1.Clone(),
+		Pool:          pool,
+		IntervalWidth: intervalWidth,
+		Window:        window,
+		Positions:     make([]Position, 0),
+	}
+}
+
+func init() {
+	Register(Definition{
+		Name:        "interval_around_twap",
+		Description: "[twap - a, twap + a] around the TWAP of the pool oracle",
+		Parameters: []Parameter{
+			intervalWidthParameter,
+			{Name: "twap_window", Type: Int, Default: 3600, Min: 1, Max: 1 << 31, Description: "seconds the TWAP averages"},
+			{Name: "observation_cardinality", Type: Int, Default: 1000, Min: 1, Max: oracle.MaxCardinality, Description: "observations the oracle keeps"},
+		},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewIntervalAroundTWAPStrategy(amount0, amount1, pool, params.Int("interval_width"), params.Int("twap_window"), params.Int("observation_cardinality"))
+		},
+	})
+}
+
+func (s *IntervalAroundTWAPStrategy) GetPool() *pool.Pool {
+	return s.Pool
+}
+
+func (s *IntervalAroundTWAPStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+// MakeSnapshot does nothing, the pool writes the observations
+func (s *IntervalAroundTWAPStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *IntervalAroundTWAPStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+func (s *IntervalAroundTWAPStrategy) GetAmounts() (*ui.Int, *ui.Int) {
+	amount0, amount1 := new(ui.Int), new(ui.Int)
+	for _, position := range s.Positions {
+		sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickLower)
+		sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickUpper)
+		liquidityAmount0, liquidityAmount1 := la.GetAmountsForLiquidity(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, position.amount)
+		amount0.Add(amount0, liquidityAmount0)
+		amount1.Add(amount1, liquidityAmount1)
+	}
+	amount0.Add(amount0, s.Amount0)
+	amount1.Add(amount1, s.Amount1)
+	return amount0, amount1
+}
+
+func (s *IntervalAroundTWAPStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
+	for _, position := range s.Positions {
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Amount0.Add(s.Amount0, amount0)
+		s.Amount1.Add(s.Amount1, amount1)
+	}
+	retamount0, retamount1 = s.Amount0.Clone(), s.Amount1.Clone()
+	s.Positions = make([]Position, 0)
+	return
+}
+
+// twap is the arithmetic mean tick of the window like OracleLibrary.consult, rounded to negative infinity.
+// Until the oracle exists for the window it is the current tick. Later the oracle must keep enough observations
+// for the window.
+func (s *IntervalAroundTWAPStrategy) twap() (int, error) {
+	tickCumulatives, _, err := s.Pool.Observe([]int{s.Window, 0})
+	if errors.Is(err, oracle.ErrUninitialized) || errors.Is(err, oracle.ErrOld) && s.Pool.Timestamp-s.Pool.Oracle.Start < s.Window {
+		return s.Pool.TickCurrent, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("twap of %d seconds: %w, increase observation_cardinality", s.Window, err)
+	}
+	delta := tickCumulatives[1] - tickCumulatives[0]
+	tick := delta / int64(s.Window)
+	if delta < 0 && delta%int64(s.Window) != 0 {
+		tick--
+	}
+	return int(tick), nil
+}
+
+func (s *IntervalAroundTWAPStrategy) getTicks() (tickLower, tickUpper int, err error) {
+	tick, err := s.twap()
+	if err != nil {
+		return
+	}
+	tickSpacing := s.Pool.TickSpacing
+	tickLower = tickmath.Round(tick-s.IntervalWidth, tickSpacing)
+	tickUpper = tickmath.Round(tick+s.IntervalWidth, tickSpacing)
+	return
+}
+
+func (s *IntervalAroundTWAPStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
+
+	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
+	if amount.IsZero() {
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
+	}
+	s.Positions = append(s.Positions, Position{
+		amount:    amount,
+		owner:     s.Owner,
+		tickLower: tickLower,
+		tickUpper: tickUpper,
+	})
+	s.Amount0.Sub(s.Amount0, amount0)
+	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
+}
+
+func (s *IntervalAroundTWAPStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
+	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
+
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
+	return
+}
+
+func (s *IntervalAroundTWAPStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
+
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
+	return
+}
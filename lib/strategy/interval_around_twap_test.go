@@ -0,0 +1,55 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"uniswap-simulator/lib/oracle"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestIntervalAroundTWAP(t *testing.T) {
+	// The oracle starts at 0 with the liquidity of the pool, at 1000 a swap moves the price down by about 2000 ticks
+	setup := func(cardinality int) (*IntervalAroundTWAPStrategy, int) {
+		p := testPool(t)
+		s := NewIntervalAroundTWAPStrategy(ui.NewInt(1_000_000_000), ui.NewInt(1_000_000_000_000_000_000), p, 1000, 3600, cardinality)
+		tickBefore := p.TickCurrent
+		p.Timestamp = 1000
+		if _, _, err := p.ExactInputSwap(ui.NewInt(650_000_000_000), p.Token0, ui.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+		return s, tickBefore
+	}
+
+	s, tickBefore := setup(100)
+	s.Pool.Timestamp = 4000
+	if _, _, err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// The window [400, 4000] saw the old tick for 600 seconds
+	twap := (600*tickBefore + 3000*s.Pool.TickCurrent) / 3600
+	if twap-s.Pool.TickCurrent < 100 {
+		t.Fatalf("twap %d too close to the tick %d", twap, s.Pool.TickCurrent)
+	}
+	position := s.Positions[0]
+	if position.tickLower != tickmath.Round(twap-1000, 10) || position.tickUpper != tickmath.Round(twap+1000, 10) {
+		t.Errorf("range [%d, %d], want it around the twap %d", position.tickLower, position.tickUpper, twap)
+	}
+
+	// Before the oracle exists for the window the range is around the current tick
+	s, _ = setup(1)
+	s.Pool.Timestamp = 2000
+	if _, _, err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if position := s.Positions[0]; position.tickLower != tickmath.Round(s.Pool.TickCurrent-1000, 10) {
+		t.Errorf("range [%d, %d], want it around the tick %d", position.tickLower, position.tickUpper, s.Pool.TickCurrent)
+	}
+
+	// Afterwards a single observation no longer covers the window
+	s, _ = setup(1)
+	s.Pool.Timestamp = 4000
+	if _, _, err := s.Init(); !errors.Is(err, oracle.ErrOld) {
+		t.Errorf("oracle with a single observation: %v", err)
+	}
+}
@@ -1,21 +1,25 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
 	ui "uniswap-simulator/uint256"
 )
 
+// LimitOrderStrategy [pc-a, pc+a]
+// Where pc is the current price.
+// Half of what is left after minting the interval is placed in range orders one tick spacing next to the price.
+// Filled orders are withdrawn and the idle amounts are placed again.
 type LimitOrderStrategy struct {
-	Amount0          *ui.Int
-	Amount1          *ui.Int
-	Pool             *pool.Pool
-	IntervalWidth    int // a in ticks
-	Positions        []Position
-	CurrentLimitTick int
-	Direction        bool
+	Account
+	Amount0       *ui.Int
+	Amount1       *ui.Int
+	Pool          *pool.Pool
+	IntervalWidth int // a in ticks
+	Positions     []Position
+	// Orders are the open range orders, they are also in Positions
+	Orders []RangeOrder
 }
 
 func (s *LimitOrderStrategy) GetAmounts() (*ui.Int, *ui.Int) {
@@ -32,115 +36,186 @@ func (s *LimitOrderStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *LimitOrderStrategy) GetDirections() bool {
-	return s.Direction
-}
-
+// NewLimitOrderStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewLimitOrderStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *LimitOrderStrategy {
 	return &LimitOrderStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     []Position{},
+		Orders:        []RangeOrder{},
 	}
 }
 
-func (s *LimitOrderStrategy) MakeSnapshot() {
-	//TODO implement me
+func init() {
+	Register(Definition{
+		Name:        "limit_order",
+		Description: "[pc - a, pc + a] and half of the remainder in a limit order",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewLimitOrderStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
+func (s *LimitOrderStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *LimitOrderStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
 func (s *LimitOrderStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
-func (s *LimitOrderStrategy) GetCurrentLimitTick() int {
-	return s.CurrentLimitTick
+func (s *LimitOrderStrategy) GetPositions() []Position {
+	return s.Positions
 }
 
-func (s *LimitOrderStrategy) GetDirection() bool {
-	return s.Direction
+func (s *LimitOrderStrategy) GetRangeOrders() []RangeOrder {
+	return s.Orders
 }
 
-func (s *LimitOrderStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *LimitOrderStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
 	}
-	s.mintAmount(tickLower, tickUpper, amount)
+	_, err = s.mintAmount(tickLower, tickUpper, amount)
+	return err
 }
 
-func (s *LimitOrderStrategy) mintAmount(tickLower, tickUpper int, amount *ui.Int) {
-	s.Positions = append(s.Positions, Position{
+func (s *LimitOrderStrategy) mintAmount(tickLower, tickUpper int, amount *ui.Int) (Position, error) {
+	position := Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
-	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return Position{}, err
+	}
+	s.Positions = append(s.Positions, position)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return position, nil
 }
 
-func (s *LimitOrderStrategy) mintHalf(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *LimitOrderStrategy) mintHalf(tickLower, tickUpper int) (position Position, ok bool, err error) {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return Position{}, false, err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	amountHalf := new(ui.Int).Div(amount, ui.NewInt(2))
 	if amountHalf.IsZero() {
-		return
+		return Position{}, false, nil
 	}
-	s.mintAmount(tickLower, tickUpper, amountHalf)
+	position, err = s.mintAmount(tickLower, tickUpper, amountHalf)
+	return position, err == nil, err
 }
 
-func (s *LimitOrderStrategy) BurnAll() (amount0, amount1 *ui.Int) {
+func (s *LimitOrderStrategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
 	amount0, amount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	s.Positions = make([]Position, 0)
+	s.Orders = make([]RangeOrder, 0)
 	return
 }
 
-func (s *LimitOrderStrategy) setPositions() {
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
-	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
-	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
-	s.mintPosition(tickLower, tickUpper)
+func (s *LimitOrderStrategy) setPositions() error {
+	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, s.Pool.TickSpacing)
+	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, s.Pool.TickSpacing)
+	if err := s.mintPosition(tickLower, tickUpper); err != nil {
+		return err
+	}
+	return s.placeOrders()
+}
 
-	// Secondary position
+// placeOrders places half of the idle amounts in range orders one tick spacing wide,
+// token0 just above the current price and token1 just below it
+func (s *LimitOrderStrategy) placeOrders() error {
+	tickSpacing := s.Pool.TickSpacing
 	if !s.Amount0.IsZero() {
-		tickLower = tickmath.Ceil(s.Pool.TickCurrent, tickSpacing)
-		tickUpper = tickLower + 10
-		s.mintHalf(tickLower, tickUpper)
-		s.CurrentLimitTick = tickUpper
-		s.Direction = true
+		tickLower := tickmath.Floor(s.Pool.TickCurrent, tickSpacing) + tickSpacing
+		position, ok, err := s.mintHalf(tickLower, tickLower+tickSpacing)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.Orders = append(s.Orders, RangeOrder{Position: position, ZeroForOne: true})
+		}
 	}
 
 	if !s.Amount1.IsZero() {
-		tickUpper = tickmath.Floor(s.Pool.TickCurrent, tickSpacing)
-		tickLower = tickUpper - 10
-		s.mintHalf(tickLower, tickUpper)
-		s.CurrentLimitTick = tickLower
-		s.Direction = false
+		tickUpper := tickmath.Floor(s.Pool.TickCurrent, tickSpacing)
+		position, ok, err := s.mintHalf(tickUpper-tickSpacing, tickUpper)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.Orders = append(s.Orders, RangeOrder{Position: position, ZeroForOne: false})
+		}
 	}
+	return nil
 }
 
-func (s *LimitOrderStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+// OnFill withdraws the filled order and places the idle amounts again
+func (s *LimitOrderStrategy) OnFill(fill Fill) error {
+	order := fill.Order
+	amount0, amount1, err := withdraw(s.Pool, order.Position)
+	if err != nil {
+		return err
+	}
+	s.Amount0.Add(s.Amount0, amount0)
+	s.Amount1.Add(s.Amount1, amount1)
+
+	positions := make([]Position, 0, len(s.Positions))
+	for _, position := range s.Positions {
+		if position != order.Position {
+			positions = append(positions, position)
+		}
+	}
+	s.Positions = positions
+	orders := make([]RangeOrder, 0, len(s.Orders))
+	for _, o := range s.Orders {
+		if o != order {
+			orders = append(orders, o)
+		}
+	}
+	s.Orders = orders
+
+	return s.placeOrders()
+}
+
+func (s *LimitOrderStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
-	s.setPositions()
+	err = s.setPositions()
 	return
 }
 
-func (s *LimitOrderStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
-	currAmount0, currAmount1 = s.BurnAll()
-	s.setPositions()
+func (s *LimitOrderStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
+	err = s.setPositions()
 	return
 }
@@ -7,43 +7,64 @@ import (
 
 // V2Strategy [mintick, maxtick]
 type NoProvisionStrategy struct {
+	Account
 	Amount0 *ui.Int
 	Amount1 *ui.Int
 	Pool    *pool.Pool
 }
 
-func (s *NoProvisionStrategy) Rebalance() (*ui.Int, *ui.Int) {
-	//TODO implement me
-	return nil, nil
+// Rebalance does nothing, the amounts are held
+func (s *NoProvisionStrategy) Rebalance() (*ui.Int, *ui.Int, error) {
+	return s.Amount0.Clone(), s.Amount1.Clone(), nil
 }
 
-func (s *NoProvisionStrategy) MakeSnapshot() {
-	//TODO implement me
+func (s *NoProvisionStrategy) MakeSnapshot() error {
+	return nil
 }
 
+func (s *NoProvisionStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+// NewNoProvisionStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewNoProvisionStrategy(amount0, amount1 *ui.Int, pool *pool.Pool) *NoProvisionStrategy {
 	return &NoProvisionStrategy{
+		Account: Account{DefaultOwner},
 		Amount0: amount0.Clone(),
 		Amount1: amount1.Clone(),
-		Pool:    pool.Clone(),
+		Pool:    pool,
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "no_provision",
+		Description: "holds the start amounts without providing liquidity",
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, _ Params) Strategy {
+			return NewNoProvisionStrategy(amount0, amount1, pool)
+		},
+	})
+}
+
 func (s *NoProvisionStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *NoProvisionStrategy) GetPositions() []Position {
+	return nil
+}
+
 func (s *NoProvisionStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := s.Amount0.Clone(), s.Amount1.Clone()
 	return amount0, amount1
 }
 
-func (s *NoProvisionStrategy) BurnAll() (amount0, amount1 *ui.Int) {
+func (s *NoProvisionStrategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	amount0, amount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	return
 }
 
-func (s *NoProvisionStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *NoProvisionStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
 	return
@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	la "uniswap-simulator/lib/liquidity_amounts"
+	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+// RangeExitStrategy [pc-a, pc+a]
+// Where pc is the current price.
+// Instead of rebalancing every update interval the interval is moved as soon as the price leaves it,
+// swapping to the ratio of the new interval.
+type RangeExitStrategy struct {
+	Account
+	NoHooks
+	Amount0       *ui.Int
+	Amount1       *ui.Int
+	Pool          *pool.Pool
+	IntervalWidth int // a in ticks
+	Positions     []Position
+}
+
+// NewRangeExitStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
+func NewRangeExitStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *RangeExitStrategy {
+	return &RangeExitStrategy{
+		Account:       Account{DefaultOwner},
+		Amount0:       amount0.Clone(),
+		Amount1:       amount1.Clone(),
+		Pool:          pool,
+		IntervalWidth: intervalWidth,
+		Positions:     make([]Position, 0),
+	}
+}
+
+func init() {
+	Register(Definition{
+		Name:        "range_exit",
+		Description: "[pc - a, pc + a] around the current price, moved as soon as the price leaves it",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewRangeExitStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
+func (s *RangeExitStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *RangeExitStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
+}
+
+func (s *RangeExitStrategy) GetPool() *pool.Pool {
+	return s.Pool
+}
+
+func (s *RangeExitStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+func (s *RangeExitStrategy) GetAmounts() (*ui.Int, *ui.Int) {
+	amount0, amount1 := new(ui.Int), new(ui.Int)
+	for _, position := range s.Positions {
+		sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickLower)
+		sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(position.tickUpper)
+		liquidityAmount0, liquidityAmount1 := la.GetAmountsForLiquidity(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, position.amount)
+		amount0.Add(amount0, liquidityAmount0)
+		amount1.Add(amount1, liquidityAmount1)
+	}
+	amount0.Add(amount0, s.Amount0)
+	amount1.Add(amount1, s.Amount1)
+	return amount0, amount1
+}
+
+func (s *RangeExitStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
+	for _, position := range s.Positions {
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Amount0.Add(s.Amount0, amount0)
+		s.Amount1.Add(s.Amount1, amount1)
+	}
+	retamount0, retamount1 = s.Amount0.Clone(), s.Amount1.Clone()
+	s.Positions = make([]Position, 0)
+	return
+}
+
+func (s *RangeExitStrategy) mintPosition() error {
+	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, s.Pool.TickSpacing)
+	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, s.Pool.TickSpacing)
+	position, ok, err := SwapAndMint(s.Pool, s.Owner, s.Amount0, s.Amount1, tickLower, tickUpper)
+	if ok {
+		s.Positions = append(s.Positions, position)
+	}
+	return err
+}
+
+func (s *RangeExitStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
+	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
+	err = s.mintPosition()
+	return
+}
+
+func (s *RangeExitStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
+	err = s.mintPosition()
+	return
+}
+
+func (s *RangeExitStrategy) OnPriceOutOfRange(Position) error {
+	_, _, err := s.Rebalance()
+	return err
+}
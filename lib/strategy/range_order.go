@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"math"
+	"math/big"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+// RangeOrder is single-sided liquidity outside the current price, which the pool converts to the other token
+// when the price crosses the whole range. ZeroForOne orders sell token0 above the price,
+// the others sell token1 below the price.
+type RangeOrder struct {
+	Position
+	ZeroForOne bool
+}
+
+// Filled reports whether the order is fully converted at tick
+func (o RangeOrder) Filled(tick int) bool {
+	if o.ZeroForOne {
+		return tick >= o.tickUpper
+	}
+	return tick < o.tickLower
+}
+
+// ExecutionPrice is the average price of token0 in token1 the order is converted at without fees,
+// the geometric mean of the prices at the bounds of the range
+func (o RangeOrder) ExecutionPrice() float64 {
+	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(o.tickLower)
+	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(o.tickUpper)
+	return math.Sqrt(SqrtPriceToFloat(sqrtRatioAX96) * SqrtPriceToFloat(sqrtRatioBX96))
+}
+
+// Fill is a range order converted by a replayed transaction
+type Fill struct {
+	Order     RangeOrder
+	Timestamp int
+	// Prices of token0 in token1. MidPrice is the pool price after the transaction that filled the order.
+	ExecutionPrice float64
+	MidPrice       float64
+}
+
+// PriceVsMid is the relative difference of the execution price to the mid price from the side of the order,
+// positive when the order was converted at a better price than the pool price at the fill
+func (f Fill) PriceVsMid() float64 {
+	if f.Order.ZeroForOne {
+		return f.ExecutionPrice/f.MidPrice - 1
+	}
+	return f.MidPrice/f.ExecutionPrice - 1
+}
+
+// RangeOrders is an optional interface for strategies placing range orders.
+// The executor checks the orders after every replayed transaction and calls OnFill for the ones
+// the transaction converted, so the strategy can withdraw them.
+type RangeOrders interface {
+	GetRangeOrders() []RangeOrder
+	OnFill(fill Fill) error
+}
+
+// SqrtPriceToFloat returns (sqrtPriceX96 / 2^96)^2
+func SqrtPriceToFloat(sqrtPriceX96 *ui.Int) float64 {
+	sqrtPrice := new(big.Float).SetInt(sqrtPriceX96.ToBig())
+	sqrtPrice.SetMantExp(sqrtPrice, -96)
+	price, _ := new(big.Float).Mul(sqrtPrice, sqrtPrice).Float64()
+	return price
+}
@@ -0,0 +1,170 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+type ParameterType string
+
+const (
+	Int   ParameterType = "int"
+	Float ParameterType = "float"
+)
+
+// Parameter describes one constructor argument of a strategy
+type Parameter struct {
+	Name        string
+	Type        ParameterType
+	Default     float64
+	Min         float64
+	Max         float64
+	Description string
+}
+
+// Params are the parameter values of a single strategy instance by name
+type Params map[string]float64
+
+func (p Params) Int(name string) int {
+	return int(p[name])
+}
+
+func (p Params) Float(name string) float64 {
+	return p[name]
+}
+
+// Factories provide liquidity in the pool they get, the registry passes a clone unless the pool is shared.
+// The New*Strategy constructors the factories call do not clone the pool, so strategies can share one.
+type Factory func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy
+
+// MultiPoolFactory constructs a MultiPool strategy over pools of the same pair
+type MultiPoolFactory func(amount0, amount1 *ui.Int, pools []*pool.Pool, params Params) Strategy
+
+// Definition describes a registered strategy. Exactly one of Factory and MultiPoolFactory is set.
+type Definition struct {
+	Name             string
+	Description      string
+	Parameters       []Parameter
+	Factory          Factory
+	MultiPoolFactory MultiPoolFactory
+}
+
+var registry = make(map[string]*Definition)
+
+// Parameters shared by several strategies
+var (
+	snapshotsParameter = Parameter{
+		Name: "snapshots", Type: Int, Default: 100, Min: 2, Max: 100_000,
+		Description: "amount of price snapshots kept in the price history",
+	}
+	multiplierParameter = Parameter{
+		Name: "multiplier", Type: Int, Default: 1024, Min: 1, Max: 1 << 16,
+		Description: "multiplier of the volatility, Q.6.10",
+	}
+	intervalWidthParameter = Parameter{
+		Name: "interval_width", Type: Int, Default: 1000, Min: 1, Max: float64(tickmath.MaxTick),
+		Description: "half width of the interval in ticks",
+	}
+)
+
+// Register makes a strategy constructable by name. It is meant to be called from init.
+func Register(definition Definition) {
+	if _, ok := registry[definition.Name]; ok {
+		panic("strategy registered twice: " + definition.Name)
+	}
+	registry[definition.Name] = &definition
+}
+
+func Lookup(name string) (*Definition, bool) {
+	definition, ok := registry[name]
+	return definition, ok
+}
+
+// List returns all registered strategies sorted by name
+func List() []*Definition {
+	definitions := make([]*Definition, 0, len(registry))
+	for _, definition := range registry {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+// New constructs the strategy registered under name in a clone of p.
+// Missing parameters take their default, unknown or out of range parameters are an error.
+func New(name string, amount0, amount1 *ui.Int, p *pool.Pool, params Params) (Strategy, error) {
+	return NewMultiPool(name, amount0, amount1, []*pool.Pool{p}, params)
+}
+
+// NewMultiPool constructs the strategy registered under name over clones of pools.
+// Single pool strategies only accept one pool.
+func NewMultiPool(name string, amount0, amount1 *ui.Int, pools []*pool.Pool, params Params) (Strategy, error) {
+	clones := make([]*pool.Pool, len(pools))
+	for i, p := range pools {
+		clones[i] = p.Clone()
+	}
+	return construct(name, amount0, amount1, clones, params)
+}
+
+// NewShared constructs the strategy registered under name in pools shared with other strategies.
+// Its positions are owned by owner, which must be unique among the strategies of the pools.
+func NewShared(name, owner string, amount0, amount1 *ui.Int, pools []*pool.Pool, params Params) (Strategy, error) {
+	strategy, err := construct(name, amount0, amount1, pools, params)
+	if err != nil {
+		return nil, err
+	}
+	account, ok := strategy.(interface{ setOwner(string) })
+	if !ok {
+		return nil, fmt.Errorf("%s: strategy has no Account", name)
+	}
+	account.setOwner(owner)
+	return strategy, nil
+}
+
+func construct(name string, amount0, amount1 *ui.Int, pools []*pool.Pool, params Params) (Strategy, error) {
+	definition, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	resolved, err := definition.Resolve(params)
+	if err != nil {
+		return nil, err
+	}
+	if definition.MultiPoolFactory != nil {
+		return definition.MultiPoolFactory(amount0, amount1, pools, resolved), nil
+	}
+	if len(pools) != 1 {
+		return nil, fmt.Errorf("%s: strategy uses a single pool, got %d", name, len(pools))
+	}
+	return definition.Factory(amount0, amount1, pools[0], resolved), nil
+}
+
+// Resolve fills in defaults and validates params against the parameter schema
+func (d *Definition) Resolve(params Params) (Params, error) {
+	resolved := make(Params, len(d.Parameters))
+	for _, parameter := range d.Parameters {
+		value, ok := params[parameter.Name]
+		if !ok {
+			value = parameter.Default
+		}
+		if parameter.Type == Int && value != math.Trunc(value) {
+			return nil, fmt.Errorf("%s: parameter %s must be an integer, got %v", d.Name, parameter.Name, value)
+		}
+		if value < parameter.Min || value > parameter.Max {
+			return nil, fmt.Errorf("%s: parameter %s must be in [%v, %v], got %v", d.Name, parameter.Name, parameter.Min, parameter.Max, value)
+		}
+		resolved[parameter.Name] = value
+	}
+	for name := range params {
+		if _, ok := resolved[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown parameter %s", d.Name, name)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,37 @@
+package strategy
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	definition, ok := Lookup("two_interval_around_price")
+	if !ok {
+		t.Fatal("two_interval_around_price is not registered")
+	}
+
+	params, err := definition.Resolve(Params{"a": 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Int("a") != 500 || params.Int("b") != 100 {
+		t.Errorf("Resolve() = %v, want a = 500 and the default b = 100", params)
+	}
+
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"unknown", Params{"c": 1}},
+		{"not integer", Params{"a": 1.5}},
+		{"below range", Params{"b": 0}},
+		{"above range", Params{"a": 1_000_000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := definition.Resolve(tt.params); err == nil {
+				t.Errorf("Resolve(%v) should fail", tt.params)
+			}
+		})
+	}
+}
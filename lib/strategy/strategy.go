@@ -1,23 +1,143 @@
 package strategy
 
 import (
+	"fmt"
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/fullmath"
 	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ent "uniswap-simulator/lib/transaction"
 	ui "uniswap-simulator/uint256"
 )
 
+// DefaultOwner owns the positions of a strategy that has its own pool
+const DefaultOwner = "strategy"
+
+// Account is embedded by the strategies, Owner is the owner of their positions in the pool
+type Account struct {
+	Owner string
+}
+
+func (a Account) GetOwner() string {
+	return a.Owner
+}
+
+func (a *Account) setOwner(owner string) {
+	a.Owner = owner
+}
+
 type Position struct {
 	amount    *ui.Int
+	owner     string
 	tickLower int
 	tickUpper int
+	// pool is the index of the pool of the position for MultiPool strategies
+	pool int
+}
+
+func (p Position) Liquidity() *ui.Int {
+	return p.amount
+}
+
+func (p Position) Owner() string {
+	return p.owner
+}
+
+func (p Position) TickLower() int {
+	return p.tickLower
+}
+
+func (p Position) TickUpper() int {
+	return p.tickUpper
 }
 
+// Pool is the index in GetPools of the pool the position is in
+func (p Position) Pool() int {
+	return p.pool
+}
+
+// Strategy is driven by the executor. Errors of the pool end the run.
 type Strategy interface {
-	Init() (*ui.Int, *ui.Int)
-	Rebalance() (*ui.Int, *ui.Int)
-	BurnAll() (*ui.Int, *ui.Int)
+	Init() (*ui.Int, *ui.Int, error)
+	Rebalance() (*ui.Int, *ui.Int, error)
+	BurnAll() (*ui.Int, *ui.Int, error)
 	GetPool() *pool.Pool
+	// GetOwner returns the owner of the positions of the strategy in the pool
+	GetOwner() string
+	// GetPositions returns the open positions of the strategy in the pool
+	GetPositions() []Position
 	GetAmounts() (*ui.Int, *ui.Int)
-	MakeSnapshot()
-	//GetCurrentLimitTick() int
-	//GetDirections() bool
+	MakeSnapshot() error
+	// PayGas deducts a gas cost in token1 from the amounts the strategy holds outside of positions
+	// and returns the part of the cost they could not cover
+	PayGas(amount1 *ui.Int) *ui.Int
+}
+
+// MultiPool is an optional interface for strategies providing liquidity in several pools of the same pair,
+// e.g. the fee tiers. The executor replays every transaction against the pool of its Pool index in GetPools.
+// GetPool is the first of the pools, its price values the amounts.
+type MultiPool interface {
+	GetPools() []*pool.Pool
+}
+
+// Hooks is an optional interface for strategies that react to events instead of the fixed interval Rebalance.
+// The executor calls the hooks after a transaction was replayed against the pool
+// and calls OnTimer instead of Rebalance every update interval.
+type Hooks interface {
+	OnTransaction(trans ent.Transaction) error
+	// OnTickCross is called when a transaction moved the current tick of the pool
+	OnTickCross(tickBefore, tickAfter int) error
+	// OnPriceOutOfRange is called when a transaction moved the current tick out of the range of a position
+	OnPriceOutOfRange(position Position) error
+	OnTimer(timestamp int) error
+}
+
+// NoHooks can be embedded by strategies that only implement some of the Hooks
+type NoHooks struct{}
+
+func (NoHooks) OnTransaction(ent.Transaction) error { return nil }
+func (NoHooks) OnTickCross(int, int) error          { return nil }
+func (NoHooks) OnPriceOutOfRange(Position) error    { return nil }
+func (NoHooks) OnTimer(int) error                   { return nil }
+
+// InRange reports whether tick is inside the range of the position
+func (p Position) InRange(tick int) bool {
+	return p.tickLower <= tick && tick < p.tickUpper
+}
+
+// withdraw burns the liquidity of position and collects it with the fees
+func withdraw(p *pool.Pool, position Position) (amount0, amount1 *ui.Int, err error) {
+	if _, _, err = p.Burn(position.owner, position.tickLower, position.tickUpper, position.amount); err != nil {
+		return nil, nil, err
+	}
+	return p.Collect(position.owner, position.tickLower, position.tickUpper)
+}
+
+// payGas deducts cost from amount1 and, if amount1 is not enough, the rest from amount0 at the spot price of p.
+// It returns the part of cost in token1 that neither covers.
+func payGas(p *pool.Pool, amount0, amount1, cost *ui.Int) *ui.Int {
+	if !amount1.Lt(cost) {
+		amount1.Sub(amount1, cost)
+		return new(ui.Int)
+	}
+	rest1 := new(ui.Int).Sub(cost, amount1)
+	amount1.Clear()
+	priceSquareX192 := new(ui.Int).Mul(p.SqrtRatioX96, p.SqrtRatioX96)
+	rest0 := fullmath.MulDiv(rest1, cons.Q192, priceSquareX192)
+	if !amount0.Lt(rest0) {
+		amount0.Sub(amount0, rest0)
+		return new(ui.Int)
+	}
+	unpaid0 := new(ui.Int).Sub(rest0, amount0)
+	amount0.Clear()
+	return fullmath.MulDiv(unpaid0, priceSquareX192, cons.Q192)
+}
+
+// sqrtRatios returns the sqrt prices at the bounds of a range computed by a strategy.
+// Ranges outside [MinTick, MaxTick], e.g. of a wide interval far from the price, are an ErrInvalidTickRange.
+func sqrtRatios(tickLower, tickUpper int) (sqrtRatioAX96, sqrtRatioBX96 *ui.Int, err error) {
+	if tickLower < tickmath.MinTick || tickUpper > tickmath.MaxTick || tickUpper < tickmath.MinTick || tickLower > tickmath.MaxTick {
+		return nil, nil, fmt.Errorf("%w: [%d, %d]", pool.ErrInvalidTickRange, tickLower, tickUpper)
+	}
+	return tickmath.TM.GetSqrtRatioAtTick(tickLower), tickmath.TM.GetSqrtRatioAtTick(tickUpper), nil
 }
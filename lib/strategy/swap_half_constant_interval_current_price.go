@@ -1,7 +1,6 @@
 package strategy
 
 import (
-	cons "uniswap-simulator/lib/constants"
 	la "uniswap-simulator/lib/liquidity_amounts"
 	"uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/tickmath"
@@ -12,6 +11,7 @@ import (
 // Where pc is the current price
 
 type IntervalAroundPriceAndSwapStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -19,14 +19,22 @@ type IntervalAroundPriceAndSwapStrategy struct {
 	Positions     []Position
 }
 
-func (s *IntervalAroundPriceAndSwapStrategy) MakeSnapshot() {
-	//TODO implement me
+func (s *IntervalAroundPriceAndSwapStrategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *IntervalAroundPriceAndSwapStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
 func (s *IntervalAroundPriceAndSwapStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *IntervalAroundPriceAndSwapStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
 func (s *IntervalAroundPriceAndSwapStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -41,20 +49,35 @@ func (s *IntervalAroundPriceAndSwapStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
+// NewIntervalAroundPriceAndSwapStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewIntervalAroundPriceAndSwapStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, intervalWidth int) *IntervalAroundPriceAndSwapStrategy {
 	return &IntervalAroundPriceAndSwapStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		IntervalWidth: intervalWidth,
 		Positions:     make([]Position, 0),
 	}
 }
 
-func (s *IntervalAroundPriceAndSwapStrategy) BurnAll() (retamount0, retamount1 *ui.Int) {
+func init() {
+	Register(Definition{
+		Name:        "interval_around_price_and_swap",
+		Description: "[pc - a, pc + a] around the current price, swaps the excess before minting",
+		Parameters:  []Parameter{intervalWidthParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewIntervalAroundPriceAndSwapStrategy(amount0, amount1, pool, params.Int("interval_width"))
+		},
+	})
+}
+
+func (s *IntervalAroundPriceAndSwapStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -63,65 +86,32 @@ func (s *IntervalAroundPriceAndSwapStrategy) BurnAll() (retamount0, retamount1 *
 	return
 }
 
-func (s *IntervalAroundPriceAndSwapStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
-
-	amountNoSwap := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
-	amount0NoSwap, amount1NoSwap := la.GetAmountsForLiquidity(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, amountNoSwap)
-
-	amount0Diff := new(ui.Int).Sub(s.Amount0, amount0NoSwap)
-
-	// 10^9 so that it is about equal to amount1
-	amount0Cmp := new(ui.Int).Lsh(amount0Diff, 9)
-	amount1Diff := new(ui.Int).Sub(s.Amount1, amount1NoSwap)
-
-	if amount0Cmp.Cmp(amount1Diff) > 0 {
-		// amount0 is more than amount1
-		amount0DiffHalf := new(ui.Int).Div(amount0Diff, ui.NewInt(2))
-		if amount0DiffHalf.Sign() == 1 {
-			amount0Swap, amount1Swap := s.Pool.ExactInputSwap(amount0DiffHalf, s.Pool.Token0, cons.Zero)
-			s.Amount0.Sub(s.Amount0, amount0Swap)
-			s.Amount1.Sub(s.Amount1, amount1Swap)
-		}
-	} else {
-		// amount1 is more than amount0
-		amount1DiffHalf := new(ui.Int).Div(amount1Diff, ui.NewInt(2))
-		if amount1DiffHalf.Sign() == 1 {
-			amount0Swap, amount1Swap := s.Pool.ExactInputSwap(amount1DiffHalf, s.Pool.Token1, cons.Zero)
-			s.Amount0.Sub(s.Amount0, amount0Swap)
-			s.Amount1.Sub(s.Amount1, amount1Swap)
-		}
+func (s *IntervalAroundPriceAndSwapStrategy) mintPosition(tickLower, tickUpper int) error {
+	position, ok, err := SwapAndMint(s.Pool, s.Owner, s.Amount0, s.Amount1, tickLower, tickUpper)
+	if ok {
+		s.Positions = append(s.Positions, position)
 	}
-	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
-	s.Positions = append(s.Positions, Position{
-		amount:    amount,
-		tickLower: tickLower,
-		tickUpper: tickUpper,
-	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
-	s.Amount0.Sub(s.Amount0, amount0)
-	s.Amount1.Sub(s.Amount1, amount1)
-
+	return err
 }
 
-func (s *IntervalAroundPriceAndSwapStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *IntervalAroundPriceAndSwapStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	// New Positions
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
-	s.mintPosition(tickLower, tickUpper)
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
 
-func (s *IntervalAroundPriceAndSwapStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
+func (s *IntervalAroundPriceAndSwapStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
 
 	// We are not interested in GasFee So just burn every time.
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -129,10 +119,10 @@ func (s *IntervalAroundPriceAndSwapStrategy) Rebalance() (currAmount0, currAmoun
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 	s.Positions = make([]Position, 0)
 
-	tickSpacing := cons.TickSpaces[s.Pool.Fee]
+	tickSpacing := s.Pool.TickSpacing
 	tickLower := tickmath.Round(s.Pool.TickCurrent-s.IntervalWidth, tickSpacing)
 	tickUpper := tickmath.Round(s.Pool.TickCurrent+s.IntervalWidth, tickSpacing)
 
-	s.mintPosition(tickLower, tickUpper)
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
@@ -0,0 +1,107 @@
+package strategy
+
+import (
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/fullmath"
+	la "uniswap-simulator/lib/liquidity_amounts"
+	"uniswap-simulator/lib/pool"
+	ui "uniswap-simulator/uint256"
+)
+
+// swapToRatioPrecision stops the search once the swap amount is known to 2^-24 of the amount
+const swapToRatioPrecision = 24
+
+// SwapToRatio swaps the excess of amount0 or amount1 in the pool, so that the amounts fit [tickLower, tickUpper]
+// at the price after the swap. The amount is found by a binary search on quotes of the swap,
+// so the fee and the price impact of the swap are included.
+// amount0 and amount1 are updated to the amounts after the swap.
+func SwapToRatio(p *pool.Pool, amount0, amount1 *ui.Int, tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
+
+	zeroForOne := excess0(p.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, amount0, amount1)
+	token, total := p.Token1, amount1
+	if zeroForOne {
+		token, total = p.Token0, amount0
+	}
+	if total.IsZero() || p.Liquidity.IsZero() {
+		return nil
+	}
+
+	// Swapping lo still leaves an excess of the input token, swapping hi does not
+	lo, hi := new(ui.Int), total.Clone()
+	precision := new(ui.Int).Rsh(total, swapToRatioPrecision)
+	for {
+		diff := new(ui.Int).Sub(hi, lo)
+		if !diff.Gt(precision) || !diff.Gt(cons.One) {
+			break
+		}
+		mid := new(ui.Int).Add(lo, new(ui.Int).Rsh(diff, 1))
+		swap0, swap1, sqrtPriceX96, err := p.QuoteExactInputSwap(mid, token, cons.Zero)
+		if err != nil {
+			return err
+		}
+		after0, after1 := new(ui.Int).Sub(amount0, swap0), new(ui.Int).Sub(amount1, swap1)
+		if excess0(sqrtPriceX96, sqrtRatioAX96, sqrtRatioBX96, after0, after1) == zeroForOne {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if lo.IsZero() {
+		return nil
+	}
+	swap0, swap1, err := p.ExactInputSwap(lo, token, cons.Zero)
+	if err != nil {
+		return err
+	}
+	amount0.Sub(amount0, swap0)
+	amount1.Sub(amount1, swap1)
+	return nil
+}
+
+// excess0 reports whether token0 is left over after minting the maximal liquidity at sqrtRatioX96,
+// comparing the leftovers in token0
+func excess0(sqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, amount0, amount1 *ui.Int) bool {
+	liquidity := la.GetLiquidityForAmount(sqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, amount0, amount1)
+	used0, used1 := la.GetAmountsForLiquidity(sqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, liquidity)
+	left0 := new(ui.Int).Sub(amount0, used0)
+	left1 := new(ui.Int).Sub(amount1, used1)
+	// Rounding may use a unit more than available
+	if left0.Sign() < 0 {
+		left0.Clear()
+	}
+	if left1.Sign() < 0 {
+		left1.Clear()
+	}
+	priceSquareX192 := new(ui.Int).Mul(sqrtRatioX96, sqrtRatioX96)
+	left1to0 := fullmath.MulDiv(left1, cons.Q192, priceSquareX192)
+	return left0.Gt(left1to0)
+}
+
+// SwapAndMint swaps to the ratio of [tickLower, tickUpper] with SwapToRatio and mints the maximal liquidity,
+// so no capital is left idle, as a position of owner. amount0 and amount1 are updated to what is left.
+// ok is false if no liquidity could be minted.
+func SwapAndMint(p *pool.Pool, owner string, amount0, amount1 *ui.Int, tickLower, tickUpper int) (position Position, ok bool, err error) {
+	if err = SwapToRatio(p, amount0, amount1, tickLower, tickUpper); err != nil {
+		return Position{}, false, err
+	}
+
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return Position{}, false, err
+	}
+	amount := la.GetLiquidityForAmount(p.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, amount0, amount1)
+	if amount.IsZero() {
+		return Position{}, false, nil
+	}
+	minted0, minted1, err := p.Mint(owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return Position{}, false, err
+	}
+	amount0.Sub(amount0, minted0)
+	amount1.Sub(amount1, minted1)
+	return Position{amount: amount, owner: owner, tickLower: tickLower, tickUpper: tickUpper}, true, nil
+}
@@ -0,0 +1,63 @@
+package strategy
+
+import (
+	"math/big"
+	"testing"
+	cons "uniswap-simulator/lib/constants"
+	"uniswap-simulator/lib/fullmath"
+	"uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/tickmath"
+	ui "uniswap-simulator/uint256"
+)
+
+// testPool returns a USDC/WETH pool with full range liquidity
+func testPool(t *testing.T) *pool.Pool {
+	t.Helper()
+	sqrtPrice, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
+	sqrtPriceX96, _ := ui.FromBig(sqrtPrice)
+	p, err := pool.NewPool("USDC", "WETH", 500, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddLiquidity(-887270, 887270, ui.NewInt(100_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSwapAndMint(t *testing.T) {
+	tests := []struct {
+		name                     string
+		amount0, amount1         uint64
+		lowerOffset, upperOffset int
+	}{
+		{"only token0", 10_000_000_000, 0, -1000, 1000},
+		{"only token1", 0, 3_000_000_000_000_000_000, -1000, 1000},
+		{"skewed range", 5_000_000_000, 1_000_000_000_000_000_000, -200, 3000},
+		{"range above price", 5_000_000_000, 1_000_000_000_000_000_000, 1000, 2000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := testPool(t)
+			tickLower := tickmath.Round(p.TickCurrent+test.lowerOffset, p.TickSpacing)
+			tickUpper := tickmath.Round(p.TickCurrent+test.upperOffset, p.TickSpacing)
+			amount0, amount1 := ui.NewInt(test.amount0), ui.NewInt(test.amount1)
+			priceSquareX192 := new(ui.Int).Mul(p.SqrtRatioX96, p.SqrtRatioX96)
+			value := new(ui.Int).Add(amount0, fullmath.MulDiv(amount1, cons.Q192, priceSquareX192))
+
+			_, ok, err := SwapAndMint(p, DefaultOwner, amount0, amount1, tickLower, tickUpper)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatal("nothing minted")
+			}
+			priceSquareX192 = new(ui.Int).Mul(p.SqrtRatioX96, p.SqrtRatioX96)
+			left := new(ui.Int).Add(amount0, fullmath.MulDiv(amount1, cons.Q192, priceSquareX192))
+			// At most 0.001% of the value is left idle
+			if new(ui.Int).Mul(left, ui.NewInt(100_000)).Gt(value) {
+				t.Errorf("left %v %v of value %v", amount0, amount1, value)
+			}
+		})
+	}
+}
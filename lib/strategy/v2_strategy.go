@@ -9,39 +9,62 @@ import (
 
 // V2Strategy [mintick, maxtick]
 type V2Strategy struct {
+	Account
 	Amount0   *ui.Int
 	Amount1   *ui.Int
 	Pool      *pool.Pool
 	Positions []Position
 }
 
-func (s *V2Strategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
-	currAmount0, currAmount1 = s.BurnAll()
+func (s *V2Strategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
 
 	// New Positions
 	tickLower := -887270
 	tickUpper := -tickLower
-	s.mintPosition(tickLower, tickUpper)
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
 
-func (s *V2Strategy) MakeSnapshot() {
-	//TODO implement me
+func (s *V2Strategy) MakeSnapshot() error {
+	return nil
+}
+
+func (s *V2Strategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
+// NewV2Strategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewV2Strategy(amount0, amount1 *ui.Int, pool *pool.Pool) *V2Strategy {
 	return &V2Strategy{
+		Account:   Account{DefaultOwner},
 		Amount0:   amount0.Clone(),
 		Amount1:   amount1.Clone(),
-		Pool:      pool.Clone(),
+		Pool:      pool,
 		Positions: make([]Position, 0),
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "v2",
+		Description: "[mintick, maxtick] like uniswap v2",
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, _ Params) Strategy {
+			return NewV2Strategy(amount0, amount1, pool)
+		},
+	})
+}
+
 func (s *V2Strategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
+func (s *V2Strategy) GetPositions() []Position {
+	return s.Positions
+}
+
 func (s *V2Strategy) GetAmounts() (*ui.Int, *ui.Int) {
 	amount0, amount1 := new(ui.Int), new(ui.Int)
 	for _, position := range s.Positions {
@@ -56,10 +79,12 @@ func (s *V2Strategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *V2Strategy) BurnAll() (amount0, amount1 *ui.Int) {
+func (s *V2Strategy) BurnAll() (amount0, amount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -68,31 +93,37 @@ func (s *V2Strategy) BurnAll() (amount0, amount1 *ui.Int) {
 	return
 }
 
-func (s *V2Strategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *V2Strategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
 	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
 }
 
-func (s *V2Strategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *V2Strategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
 	// New Positions
 	tickLower := -887270
 	tickUpper := -tickLower
-	s.mintPosition(tickLower, tickUpper)
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
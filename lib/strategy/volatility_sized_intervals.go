@@ -13,6 +13,7 @@ import (
 // c is a constant
 // o is the volatility
 type VolatilitySizedIntervalStrategy struct {
+	Account
 	Amount0       *ui.Int
 	Amount1       *ui.Int
 	Pool          *pool.Pool
@@ -21,27 +22,47 @@ type VolatilitySizedIntervalStrategy struct {
 	PriceHistory  *prices.Prices
 }
 
+// NewVolatilitySizedIntervalStrategy changes pool, it is not cloned. Pass a clone to keep using pool without the strategy.
 func NewVolatilitySizedIntervalStrategy(amount0, amount1 *ui.Int, pool *pool.Pool, amountAverageSnapshots, multiplier int) *VolatilitySizedIntervalStrategy {
 	priceHistory := prices.NewPrices(amountAverageSnapshots)
 	multiplierX10 := ui.NewInt(uint64(multiplier))
 	return &VolatilitySizedIntervalStrategy{
+		Account:       Account{DefaultOwner},
 		Amount0:       amount0.Clone(),
 		Amount1:       amount1.Clone(),
-		Pool:          pool.Clone(),
+		Pool:          pool,
 		Positions:     make([]Position, 0),
 		MultiplierX10: multiplierX10,
 		PriceHistory:  priceHistory,
 	}
 }
 
+func init() {
+	Register(Definition{
+		Name:        "volatility_sized_interval",
+		Description: "[pc - c*o, pc + c*o] around the current price",
+		Parameters:  []Parameter{snapshotsParameter, multiplierParameter},
+		Factory: func(amount0, amount1 *ui.Int, pool *pool.Pool, params Params) Strategy {
+			return NewVolatilitySizedIntervalStrategy(amount0, amount1, pool, params.Int("snapshots"), params.Int("multiplier"))
+		},
+	})
+}
+
 func (s *VolatilitySizedIntervalStrategy) GetPool() *pool.Pool {
 	return s.Pool
 }
 
-func (s *VolatilitySizedIntervalStrategy) MakeSnapshot() {
+func (s *VolatilitySizedIntervalStrategy) GetPositions() []Position {
+	return s.Positions
+}
+
+func (s *VolatilitySizedIntervalStrategy) MakeSnapshot() error {
 	sqrtPriceX96 := s.Pool.SqrtRatioX96
-	s.PriceHistory.Add(sqrtPriceX96)
+	return s.PriceHistory.Add(sqrtPriceX96)
+}
 
+func (s *VolatilitySizedIntervalStrategy) PayGas(amount1 *ui.Int) *ui.Int {
+	return payGas(s.GetPool(), s.Amount0, s.Amount1, amount1)
 }
 
 func (s *VolatilitySizedIntervalStrategy) GetAmounts() (*ui.Int, *ui.Int) {
@@ -58,10 +79,12 @@ func (s *VolatilitySizedIntervalStrategy) GetAmounts() (*ui.Int, *ui.Int) {
 	return amount0, amount1
 }
 
-func (s *VolatilitySizedIntervalStrategy) BurnAll() (retamount0, retamount1 *ui.Int) {
+func (s *VolatilitySizedIntervalStrategy) BurnAll() (retamount0, retamount1 *ui.Int, err error) {
 	for _, position := range s.Positions {
-		s.Pool.BurnStrategy(position.tickLower, position.tickUpper, position.amount)
-		amount0, amount1 := s.Pool.CollectStrategy(position.tickLower, position.tickUpper)
+		amount0, amount1, err := withdraw(s.Pool, position)
+		if err != nil {
+			return nil, nil, err
+		}
 		s.Amount0.Add(s.Amount0, amount0)
 		s.Amount1.Add(s.Amount1, amount1)
 	}
@@ -70,7 +93,7 @@ func (s *VolatilitySizedIntervalStrategy) BurnAll() (retamount0, retamount1 *ui.
 	return
 }
 
-func (s *VolatilitySizedIntervalStrategy) getTicks() (tickLower, tickUpper int) {
+func (s *VolatilitySizedIntervalStrategy) getTicks() (tickLower, tickUpper int, err error) {
 	volatilityX192 := s.PriceHistory.Volatility()
 	sqrtPriceX96 := s.Pool.SqrtRatioX96
 	volatilityScaledX202 := new(ui.Int).Mul(volatilityX192, s.MultiplierX10)
@@ -83,49 +106,67 @@ func (s *VolatilitySizedIntervalStrategy) getTicks() (tickLower, tickUpper int)
 	if overflow0 || sqrtRatioAX96.Cmp(tickmath.MinSqrtRatio) == -1 {
 		tickLower = tickmath.MinTick
 	} else {
-		tickLower = tickmath.TM.GetTickAtSqrtRatio(sqrtRatioAX96)
+		if tickLower, err = tickmath.TM.GetTickAtSqrtRatio(sqrtRatioAX96); err != nil {
+			return
+		}
 	}
 
 	if overflow1 || sqrtRatioBX96.Cmp(tickmath.MaxSqrtRatio) == 1 {
 		tickUpper = tickmath.MaxTick
 	} else {
-		tickUpper = tickmath.TM.GetTickAtSqrtRatio(sqrtRatioBX96)
+		if tickUpper, err = tickmath.TM.GetTickAtSqrtRatio(sqrtRatioBX96); err != nil {
+			return
+		}
 	}
 
 	return
 }
 
-func (s *VolatilitySizedIntervalStrategy) mintPosition(tickLower, tickUpper int) {
-	sqrtRatioAX96 := tickmath.TM.GetSqrtRatioAtTick(tickLower)
-	sqrtRatioBX96 := tickmath.TM.GetSqrtRatioAtTick(tickUpper)
+func (s *VolatilitySizedIntervalStrategy) mintPosition(tickLower, tickUpper int) error {
+	sqrtRatioAX96, sqrtRatioBX96, err := sqrtRatios(tickLower, tickUpper)
+	if err != nil {
+		return err
+	}
 
 	amount := la.GetLiquidityForAmount(s.Pool.SqrtRatioX96, sqrtRatioAX96, sqrtRatioBX96, s.Amount0, s.Amount1)
 	if amount.IsZero() {
-		return
+		return nil
+	}
+	amount0, amount1, err := s.Pool.Mint(s.Owner, tickLower, tickUpper, amount)
+	if err != nil {
+		return err
 	}
 	s.Positions = append(s.Positions, Position{
 		amount:    amount,
+		owner:     s.Owner,
 		tickLower: tickLower,
 		tickUpper: tickUpper,
 	})
-
-	amount0, amount1 := s.Pool.MintStrategy(tickLower, tickUpper, amount)
 	s.Amount0.Sub(s.Amount0, amount0)
 	s.Amount1.Sub(s.Amount1, amount1)
+	return nil
 }
 
-func (s *VolatilitySizedIntervalStrategy) Init() (currAmount0, currAmount1 *ui.Int) {
+func (s *VolatilitySizedIntervalStrategy) Init() (currAmount0, currAmount1 *ui.Int, err error) {
 	currAmount0, currAmount1 = s.Amount0.Clone(), s.Amount1.Clone()
 
-	tickLower, tickUpper := s.getTicks()
-	s.mintPosition(tickLower, tickUpper)
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
 
-func (s *VolatilitySizedIntervalStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int) {
-	currAmount0, currAmount1 = s.BurnAll()
+func (s *VolatilitySizedIntervalStrategy) Rebalance() (currAmount0, currAmount1 *ui.Int, err error) {
+	if currAmount0, currAmount1, err = s.BurnAll(); err != nil {
+		return
+	}
 
-	tickLower, tickUpper := s.getTicks()
-	s.mintPosition(tickLower, tickUpper)
+	tickLower, tickUpper, err := s.getTicks()
+	if err != nil {
+		return
+	}
+	err = s.mintPosition(tickLower, tickUpper)
 	return
 }
@@ -8,7 +8,7 @@ import (
 
 var MaxFee = new(ui.Int).Exp(ui.NewInt(10), ui.NewInt(6))
 
-func ComputeSwapStep(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, amountRemainingI *ui.Int, feePips int) (sqrtRatioNextX96, amountIn, amountOut, feeAmount *ui.Int) {
+func ComputeSwapStep(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, amountRemainingI *ui.Int, feePips int) (sqrtRatioNextX96, amountIn, amountOut, feeAmount *ui.Int, err error) {
 	zeroForOne := sqrtRatioCurrentX96.Cmp(sqrtRatioTargetX96) >= 0
 
 	exactIn := amountRemainingI.Sign() >= 0
@@ -24,7 +24,9 @@ func ComputeSwapStep(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, amountR
 		if amountRemainingLessFee.Cmp(amountIn) >= 0 {
 			sqrtRatioNextX96 = sqrtRatioTargetX96.Clone()
 		} else {
-			sqrtRatioNextX96 = sqrtmath.GetNextSqrtPriceFromInput(sqrtRatioCurrentX96, liquidity, amountRemainingLessFee, zeroForOne)
+			if sqrtRatioNextX96, err = sqrtmath.GetNextSqrtPriceFromInput(sqrtRatioCurrentX96, liquidity, amountRemainingLessFee, zeroForOne); err != nil {
+				return
+			}
 		}
 	} else {
 		if zeroForOne {
@@ -35,8 +37,9 @@ func ComputeSwapStep(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, amountR
 		if amountRemainingINeg.Cmp(amountOut) >= 0 {
 			sqrtRatioNextX96 = sqrtRatioTargetX96.Clone()
 		} else {
-			sqrtRatioNextX96 = sqrtmath.GetNextSqrtPriceFromOutput(sqrtRatioCurrentX96, liquidity, amountRemainingINeg, zeroForOne)
-
+			if sqrtRatioNextX96, err = sqrtmath.GetNextSqrtPriceFromOutput(sqrtRatioCurrentX96, liquidity, amountRemainingINeg, zeroForOne); err != nil {
+				return
+			}
 		}
 	}
 
@@ -17,6 +17,9 @@ func Test(t *testing.T) {
 	liquidity, _ := ui.FromBig(liquidity_big)
 	amountRemaining_big, _ := new(big.Int).SetString("26412237337162431364", 10)
 	amountRemaining, _ := ui.FromBig(amountRemaining_big)
-	sqrtPriceX96, amountIn, amountOut, feeAmount := ComputeSwapStep(current, target, liquidity, amountRemaining, 500)
+	sqrtPriceX96, amountIn, amountOut, feeAmount, err := ComputeSwapStep(current, target, liquidity, amountRemaining, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Printf("%d %d %d %d \n", sqrtPriceX96, amountIn, amountOut, feeAmount)
 }
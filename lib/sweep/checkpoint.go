@@ -0,0 +1,148 @@
+package sweep
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"uniswap-simulator/lib/result"
+)
+
+// Checkpoint is an append-only JSONL file of finished runs.
+// Runs are buffered and flushed every FlushInterval, so a killed job loses at most that much work.
+type Checkpoint struct {
+	FlushInterval time.Duration
+	file          *os.File
+	writer        *bufio.Writer
+	encoder       *json.Encoder
+	lastFlush     time.Time
+	mu            sync.Mutex
+}
+
+// OpenCheckpoint creates the checkpoint at filepath.
+// With resume the runs already in the file are returned and new runs are appended,
+// otherwise the file is truncated.
+func OpenCheckpoint(filepath string, resume bool) (*Checkpoint, []result.RunResult, error) {
+	var results []result.RunResult
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		var err error
+		results, err = readCheckpoint(filepath)
+		if err != nil {
+			return nil, nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(filepath, flags, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer := bufio.NewWriter(file)
+	return &Checkpoint{
+		FlushInterval: 30 * time.Second,
+		file:          file,
+		writer:        writer,
+		encoder:       json.NewEncoder(writer),
+		lastFlush:     time.Now(),
+	}, results, nil
+}
+
+// readCheckpoint reads all complete lines. A line cut off by a killed job is removed from the file.
+func readCheckpoint(filepath string) ([]result.RunResult, error) {
+	data, err := ioutil.ReadFile(filepath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := os.Truncate(filepath, int64(complete)); err != nil {
+			return nil, err
+		}
+	}
+	var results []result.RunResult
+	for i, line := range bytes.Split(data[:complete], []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var r result.RunResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("checkpoint %s line %d: %w", filepath, i+1, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (c *Checkpoint) Add(r result.RunResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.encoder.Encode(r); err != nil {
+		return err
+	}
+	if time.Since(c.lastFlush) >= c.FlushInterval {
+		c.lastFlush = time.Now()
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *Checkpoint) flush() error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+func (c *Checkpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// Key identifies a point independent of map order
+func (p Point) Key() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatFloat(p[name], 'g', -1, 64))
+	}
+	return b.String()
+}
+
+// Remaining returns the points that have no result yet
+func Remaining(points []Point, results []result.RunResult) []Point {
+	done := make(map[string]bool, len(results))
+	for _, r := range results {
+		done[Point(r.Parameters).Key()] = true
+	}
+	remaining := make([]Point, 0, len(points))
+	for _, p := range points {
+		if !done[p.Key()] {
+			remaining = append(remaining, p)
+		}
+	}
+	return remaining
+}
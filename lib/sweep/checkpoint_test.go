@@ -0,0 +1,58 @@
+package sweep
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"uniswap-simulator/lib/result"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filepath := path.Join(dir, "sweep.checkpoint.jsonl")
+
+	checkpoint, _, err := OpenCheckpoint(filepath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := []Point{{"a": 1, "b": 2}, {"a": 2, "b": 2}, {"a": 3, "b": 2}}
+	for _, p := range points[:2] {
+		if err := checkpoint.Add(result.RunResult{Parameters: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := checkpoint.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// A job killed while writing leaves half a line
+	file, _ := os.OpenFile(filepath, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"parameters":{"a":3,`)
+	file.Close()
+
+	checkpoint, previous, err := OpenCheckpoint(filepath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 2 {
+		t.Fatalf("resumed %d runs, want 2", len(previous))
+	}
+	remaining := Remaining(points, previous)
+	if len(remaining) != 1 || remaining[0].Key() != "a=3,b=2" {
+		t.Fatalf("Remaining() = %v, want only a=3,b=2", remaining)
+	}
+	checkpoint.Add(result.RunResult{Parameters: remaining[0]})
+	checkpoint.Close()
+
+	_, previous, err = OpenCheckpoint(filepath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 3 {
+		t.Errorf("resumed %d runs, want 3", len(previous))
+	}
+}
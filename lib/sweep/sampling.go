@@ -0,0 +1,94 @@
+package sweep
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const (
+	Grid           = "grid"
+	Random         = "random"
+	LatinHypercube = "latin_hypercube"
+)
+
+// Sampling selects how points are drawn from a Space.
+// Random and latin hypercube sampling draw Samples points and are reproducible for the same Seed.
+type Sampling struct {
+	Method  string `json:"method"`
+	Samples int    `json:"samples"`
+	Seed    int64  `json:"seed"`
+}
+
+func (s Sampling) Points(space Space) ([]Point, error) {
+	if err := space.Validate(); err != nil {
+		return nil, err
+	}
+	switch s.Method {
+	case Grid, "":
+		return GridPoints(space), nil
+	case Random:
+		if s.Samples <= 0 {
+			return nil, fmt.Errorf("random sampling needs a positive amount of samples")
+		}
+		return RandomPoints(space, s.Samples, rand.New(rand.NewSource(s.Seed))), nil
+	case LatinHypercube:
+		if s.Samples <= 0 {
+			return nil, fmt.Errorf("latin hypercube sampling needs a positive amount of samples")
+		}
+		return LatinHypercubePoints(space, s.Samples, rand.New(rand.NewSource(s.Seed))), nil
+	}
+	return nil, fmt.Errorf("unknown sampling method %q", s.Method)
+}
+
+// GridPoints returns the cartesian product of all dimensions
+func GridPoints(space Space) []Point {
+	points := []Point{space.point()}
+	for _, name := range space.names() {
+		values := space.Dimensions[name].Grid()
+		next := make([]Point, 0, len(points)*len(values))
+		for _, point := range points {
+			for _, value := range values {
+				p := make(Point, len(point)+1)
+				for k, v := range point {
+					p[k] = v
+				}
+				p[name] = value
+				next = append(next, p)
+			}
+		}
+		points = next
+	}
+	return points
+}
+
+// RandomPoints draws every dimension independently and uniformly
+func RandomPoints(space Space, n int, rng *rand.Rand) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = space.point()
+	}
+	for _, name := range space.names() {
+		dimension := space.Dimensions[name]
+		for _, point := range points {
+			point[name] = dimension.at(rng.Float64())
+		}
+	}
+	return points
+}
+
+// LatinHypercubePoints splits every dimension into n strata and draws each stratum exactly once
+func LatinHypercubePoints(space Space, n int, rng *rand.Rand) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = space.point()
+	}
+	for _, name := range space.names() {
+		dimension := space.Dimensions[name]
+		strata := rng.Perm(n)
+		for i, point := range points {
+			u := (float64(strata[i]) + rng.Float64()) / float64(n)
+			point[name] = dimension.at(u)
+		}
+	}
+	return points
+}
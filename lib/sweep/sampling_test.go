@@ -0,0 +1,97 @@
+package sweep
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGridPoints(t *testing.T) {
+	space := Space{
+		Fixed: Point{"snapshots": 100},
+		Dimensions: map[string]Dimension{
+			"a": {From: 10, To: 30, Step: 10},
+			"b": {Values: []float64{1, 2}},
+		},
+	}
+	points := GridPoints(space)
+	if len(points) != 6 {
+		t.Fatalf("len(GridPoints()) = %d, want 6", len(points))
+	}
+	seen := make(map[[2]float64]bool)
+	for _, p := range points {
+		if p["snapshots"] != 100 {
+			t.Errorf("fixed parameter missing in %v", p)
+		}
+		seen[[2]float64{p["a"], p["b"]}] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("GridPoints() has duplicates: %v", points)
+	}
+}
+
+func TestLatinHypercubePoints(t *testing.T) {
+	n := 50
+	space := Space{
+		Dimensions: map[string]Dimension{
+			"a": {From: 0, To: 1000},
+			"b": {From: 0, To: 49, Integer: true},
+		},
+	}
+	points := LatinHypercubePoints(space, n, rand.New(rand.NewSource(1)))
+	strata := make([]int, n)
+	for _, p := range points {
+		a := p["a"]
+		if a < 0 || a > 1000 {
+			t.Fatalf("a = %v out of range", a)
+		}
+		strata[int(a/1000*float64(n))]++
+		if b := p["b"]; b != float64(int(b)) {
+			t.Errorf("b = %v is not an integer", b)
+		}
+	}
+	for i, count := range strata {
+		if count != 1 {
+			t.Errorf("stratum %d drawn %d times, want once", i, count)
+		}
+	}
+}
+
+func TestSamplingIsReproducible(t *testing.T) {
+	space := Space{Dimensions: map[string]Dimension{"a": {From: 1, To: 100, Step: 1}}}
+	sampling := Sampling{Method: Random, Samples: 10, Seed: 42}
+	first, _ := sampling.Points(space)
+	second, _ := sampling.Points(space)
+	for i := range first {
+		if first[i]["a"] != second[i]["a"] {
+			t.Fatalf("same seed drew %v and %v", first[i], second[i])
+		}
+	}
+}
+
+func TestGeometricDimension(t *testing.T) {
+	d := Dimension{From: 1, To: 100_000_000, Step: 10, Geometric: true}
+	if err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	values := d.Grid()
+	if len(values) != 9 || values[0] != 1 || values[8] != 100_000_000 {
+		t.Fatalf("grid %v", values)
+	}
+	for i := 1; i < len(values); i++ {
+		if ratio := values[i] / values[i-1]; math.Abs(ratio-10) > 1e-9 {
+			t.Errorf("%v follows %v", values[i], values[i-1])
+		}
+	}
+	// Sampled values are on the grid
+	for _, u := range []float64{0, 0.3, 0.99} {
+		value := d.at(u)
+		exponent := math.Log10(value)
+		if math.Abs(exponent-math.Round(exponent)) > 1e-9 {
+			t.Errorf("at(%v) = %v is not a power of 10", u, value)
+		}
+	}
+	if err := (Dimension{From: 0, To: 10, Step: 10, Geometric: true}).Validate(); err == nil {
+		t.Error("geometric range from 0")
+	}
+}
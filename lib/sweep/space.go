@@ -0,0 +1,139 @@
+package sweep
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// UpdateInterval is not a strategy parameter but can be swept like one
+const UpdateInterval = "update_interval"
+
+// Notional scales the start amounts of a run, it can be swept like UpdateInterval
+const Notional = "notional"
+
+// Point is one parameter set of a sweep by name
+type Point map[string]float64
+
+// Dimension is one axis of the parameter space.
+// Either an explicit list of values or the range [From, To], on a grid of Step if Step is set.
+// A Geometric range multiplies by Step instead of adding it and is sampled uniformly in log space.
+type Dimension struct {
+	From      float64   `json:"from"`
+	To        float64   `json:"to"`
+	Step      float64   `json:"step"`
+	Values    []float64 `json:"values"`
+	Integer   bool      `json:"integer"`
+	Geometric bool      `json:"geometric"`
+}
+
+// Space is the parameter space of a sweep. Fixed parameters are added to every point.
+type Space struct {
+	Fixed      Point
+	Dimensions map[string]Dimension
+}
+
+func (d Dimension) Validate() error {
+	if len(d.Values) > 0 {
+		return nil
+	}
+	if d.To < d.From || d.Step < 0 {
+		return fmt.Errorf("need values or from <= to with a non negative step")
+	}
+	if d.Geometric && (d.From <= 0 || (d.Step != 0 && d.Step <= 1)) {
+		return fmt.Errorf("a geometric range needs from > 0 and a step > 1")
+	}
+	return nil
+}
+
+// Grid returns every value of the dimension. A range without step only has its bounds.
+func (d Dimension) Grid() []float64 {
+	if len(d.Values) > 0 {
+		return d.Values
+	}
+	if d.Step == 0 {
+		if d.From == d.To {
+			return []float64{d.From}
+		}
+		return []float64{d.snap(d.From), d.snap(d.To)}
+	}
+	if d.Geometric {
+		return d.geometricGrid()
+	}
+	values := make([]float64, 0, int((d.To-d.From)/d.Step)+1)
+	for i := 0; ; i++ {
+		// Multiply instead of accumulating so float steps don't drift
+		value := d.From + float64(i)*d.Step
+		if value > d.To {
+			break
+		}
+		values = append(values, d.snap(value))
+	}
+	return values
+}
+
+func (d Dimension) geometricGrid() []float64 {
+	values := make([]float64, 0)
+	for i := 0; ; i++ {
+		// Power instead of accumulating, with a little slack so To itself is not lost to rounding
+		value := d.From * math.Pow(d.Step, float64(i))
+		if value > d.To*(1+1e-9) {
+			break
+		}
+		values = append(values, d.snap(math.Min(value, d.To)))
+	}
+	return values
+}
+
+// at maps u in [0, 1) onto the dimension
+func (d Dimension) at(u float64) float64 {
+	if len(d.Values) > 0 {
+		return d.Values[int(u*float64(len(d.Values)))]
+	}
+	if d.Geometric {
+		exponent := u * math.Log(d.To/d.From)
+		if d.Step > 0 {
+			exponent = math.Round(exponent/math.Log(d.Step)) * math.Log(d.Step)
+		}
+		return d.snap(math.Min(d.From*math.Exp(exponent), d.To))
+	}
+	value := d.From + u*(d.To-d.From)
+	if d.Step > 0 {
+		value = d.From + math.Round((value-d.From)/d.Step)*d.Step
+	}
+	return d.snap(math.Min(value, d.To))
+}
+
+func (d Dimension) snap(value float64) float64 {
+	if d.Integer {
+		return math.Round(value)
+	}
+	return value
+}
+
+// names returns the dimension names sorted so sampling is deterministic
+func (s Space) names() []string {
+	names := make([]string, 0, len(s.Dimensions))
+	for name := range s.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Space) point() Point {
+	p := make(Point, len(s.Fixed)+len(s.Dimensions))
+	for k, v := range s.Fixed {
+		p[k] = v
+	}
+	return p
+}
+
+func (s Space) Validate() error {
+	for name, dimension := range s.Dimensions {
+		if err := dimension.Validate(); err != nil {
+			return fmt.Errorf("dimension %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package sweep
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"uniswap-simulator/lib/executor"
+	"uniswap-simulator/lib/result"
+)
+
+// Build creates the execution of a single point.
+// It is called by the worker right before the run, so only one strategy and pool clone per worker is alive.
+type Build func(p Point) (*executor.Execution, error)
+
+type Sweep struct {
+	Points        []Point
+	Build         Build
+	Scheduler     *executor.Scheduler
+	HistoryWindow int
+	// Checkpoint receives every finished run if set
+	Checkpoint *Checkpoint
+	// SnapshotDir receives the snapshot series of every run as <point key>.json if set
+	SnapshotDir string
+}
+
+// Run executes every point on the scheduler.
+// The results of the completed points are returned in the order of Points,
+// together with ctx.Err() if the sweep was cancelled.
+// A point whose execution fails is recorded with the error in RunResult.Error and the sweep continues.
+func (s *Sweep) Run(ctx context.Context) ([]result.RunResult, error) {
+	results := make([]result.RunResult, len(s.Points))
+	errs := make([]error, len(s.Points))
+	done := make([]bool, len(s.Points))
+
+	err := s.Scheduler.Run(ctx, len(s.Points), func(i int) {
+		results[i], errs[i] = s.run(s.Points[i])
+		if errs[i] == nil && s.Checkpoint != nil {
+			errs[i] = s.Checkpoint.Add(results[i])
+		}
+		done[i] = true
+	})
+
+	completed := make([]result.RunResult, 0, len(results))
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if done[i] {
+			completed = append(completed, results[i])
+		}
+	}
+	return completed, err
+}
+
+// run executes a point. Only failing to write the output is returned as error.
+// A panic is a bug of the simulator and is not recovered, the checkpoint keeps the finished runs.
+func (s *Sweep) run(p Point) (result.RunResult, error) {
+	execution, err := s.Build(p)
+	if err != nil {
+		return s.failed(p, err), nil
+	}
+	execution.RecordSnapshots = s.SnapshotDir != ""
+	if err := execution.Run(); err != nil {
+		r := s.failed(p, err)
+		r.UpdateInterval = execution.UpdateInterval
+		return r, nil
+	}
+	if execution.RecordSnapshots {
+		series := result.Series{Parameters: p, UpdateInterval: execution.UpdateInterval, Snapshots: execution.Snapshots}
+		if err := writeSeries(filepath.Join(s.SnapshotDir, p.Key()+".json"), series); err != nil {
+			return result.RunResult{}, err
+		}
+	}
+	return NewResult(execution, p, s.HistoryWindow), nil
+}
+
+// NewResult computes the metrics of a finished execution of p
+func NewResult(execution *executor.Execution, p Point, historyWindow int) result.RunResult {
+	r := result.NewRunResult(execution.AmountUSDSnapshots)
+	r.Attribute(execution.Attribution())
+	r.GasUnits = execution.GasUnits
+	r.GasCost = execution.GasCost.ToBig().String()
+	r.LimitOrderFills = len(execution.Fills)
+	r.FillPriceVsMid = execution.FillPriceVsMid()
+	r.Divergences = execution.Divergences
+	r.SetParameters(p)
+	r.UpdateInterval = execution.UpdateInterval
+	r.HistoryWindow = historyWindow
+	return r
+}
+
+func (s *Sweep) failed(p Point, err error) result.RunResult {
+	r := result.RunResult{HistoryWindow: s.HistoryWindow, Error: err.Error()}
+	r.SetParameters(p)
+	return r
+}
+
+func writeSeries(path string, series result.Series) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(series); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
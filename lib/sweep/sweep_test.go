@@ -0,0 +1,79 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"uniswap-simulator/lib/executor"
+	ppool "uniswap-simulator/lib/pool"
+	strat "uniswap-simulator/lib/strategy"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+// testPool returns a USDC/WETH pool with full range liquidity
+func testPool(t *testing.T) *ppool.Pool {
+	t.Helper()
+	sqrtPrice, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
+	sqrtPriceX96, _ := ui.FromBig(sqrtPrice)
+	pool, err := ppool.NewPool("USDC", "WETH", 500, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddLiquidity(-887270, 887270, ui.NewInt(1_000_000_000_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestFailedRuns(t *testing.T) {
+	pool := testPool(t)
+	swap := func(timestamp, pool int) ent.Transaction {
+		return ent.Transaction{Type: "Swap", Timestamp: timestamp, Pool: pool, Amount0: ui.NewInt(1_000), Amount1: new(ui.Int)}
+	}
+
+	s := Sweep{
+		Points:    []Point{{"a": 1}, {"a": 2}, {"a": 3}, {"a": 4, "interval_width": 800000}},
+		Scheduler: executor.NewScheduler(nil),
+		Build: func(p Point) (*executor.Execution, error) {
+			transactions := ent.Slice{swap(0, 0), swap(4000, 0)}
+			switch p["a"] {
+			case 2:
+				return nil, errors.New("no strategy")
+			case 3:
+				transactions = ent.Slice{swap(0, 0), swap(4000, 1)}
+			case 4:
+				// The interval reaches beyond MaxTick
+				strategy, err := strat.New("interval_around_price", ui.NewInt(1_000_000), ui.NewInt(1_000_000), pool, strat.Params{"interval_width": p["interval_width"]})
+				if err != nil {
+					return nil, err
+				}
+				return executor.CreateExecution(strategy, 0, math.MaxInt64, 3600, 3600, 60, transactions), nil
+			}
+			strategy := strat.NewNoProvisionStrategy(ui.NewInt(1_000_000), ui.NewInt(1_000_000), pool.Clone())
+			return executor.CreateExecution(strategy, 0, math.MaxInt64, 3600, 3600, 60, transactions), nil
+		},
+	}
+	results, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("%d results, want 4", len(results))
+	}
+	if results[0].Error != "" {
+		t.Errorf("run a=1 failed: %s", results[0].Error)
+	}
+	if results[1].Error != "no strategy" || results[1].Parameters["a"] != 2 {
+		t.Errorf("run a=2: %+v", results[1])
+	}
+	if !strings.Contains(results[2].Error, executor.ErrUnknownPool.Error()) || results[2].Parameters["a"] != 3 {
+		t.Errorf("run a=3: %+v", results[2])
+	}
+	if !strings.Contains(results[3].Error, ppool.ErrInvalidTickRange.Error()) {
+		t.Errorf("run interval_width=800000: %+v", results[3])
+	}
+}
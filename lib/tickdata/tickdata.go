@@ -1,12 +1,15 @@
 package tickdata
 
 import (
+	"errors"
 	"fmt"
 	cons "uniswap-simulator/lib/constants"
-	"uniswap-simulator/lib/invariant"
 	ui "uniswap-simulator/uint256"
 )
 
+// ErrTickNotFound is returned for a tick that is not initialized
+var ErrTickNotFound = errors.New("tick not initialized")
+
 type Tick struct {
 	Index                 int
 	LiquidityNet          *ui.Int
@@ -20,12 +23,21 @@ type TickData struct {
 	tickSpacing int
 }
 
+// Clone copies the ticks with their values, so changing the clone does not change t
 func (t *TickData) Clone() *TickData {
 	newTickData := &TickData{
 		ticks:       make([]Tick, len(t.ticks)),
 		tickSpacing: t.tickSpacing,
 	}
-	copy(newTickData.ticks, t.ticks)
+	for i, tick := range t.ticks {
+		newTickData.ticks[i] = Tick{
+			Index:                 tick.Index,
+			LiquidityNet:          tick.LiquidityNet.Clone(),
+			LiquidityGross:        tick.LiquidityGross.Clone(),
+			FeeGrowthOutside0X128: tick.FeeGrowthOutside0X128.Clone(),
+			FeeGrowthOutside1X128: tick.FeeGrowthOutside1X128.Clone(),
+		}
+	}
 	return newTickData
 }
 
@@ -43,29 +55,32 @@ func NewTickData(tickSpacing int) *TickData {
 	}
 }
 
+// isBelowSmallest and isAtOrAboveLargest are true for every tick if there are no ticks
 func (t *TickData) isBelowSmallest(tick int) bool {
-	invariant.Invariant(len(t.ticks) > 0, "TickData is empty")
-	return tick < t.ticks[0].Index
+	return len(t.ticks) == 0 || tick < t.ticks[0].Index
 }
 
 func (t *TickData) isAtOrAboveLargest(tick int) bool {
-	invariant.Invariant(len(t.ticks) > 0, "TickData is empty")
-	return tick >= t.ticks[len(t.ticks)-1].Index
+	return len(t.ticks) == 0 || tick >= t.ticks[len(t.ticks)-1].Index
 }
 func (t *TickData) isAboveLargest(tick int) bool {
 
 	return tick > t.ticks[len(t.ticks)-1].Index
 }
 
-func (t *TickData) GetTick(index int) Tick {
-	tick := t.ticks[t.binarySearch(index)]
-	invariant.Invariant(tick.Index == index, "tick index not contained")
-	return tick
+func (t *TickData) GetTick(index int) (Tick, error) {
+	i, found := t.binarySearch2(index)
+	if !found {
+		return Tick{}, fmt.Errorf("%w: %d", ErrTickNotFound, index)
+	}
+	return t.ticks[i], nil
 }
 
-func (t *TickData) Cross(tick int, feeGrowthGlobal0X128, feeGrowthGlobal1X128 *ui.Int) (liquidityNet *ui.Int) {
-
-	info := t.GetTick(tick)
+func (t *TickData) Cross(tick int, feeGrowthGlobal0X128, feeGrowthGlobal1X128 *ui.Int) (liquidityNet *ui.Int, err error) {
+	info, err := t.GetTick(tick)
+	if err != nil {
+		return nil, err
+	}
 	info.FeeGrowthOutside0X128.Sub(feeGrowthGlobal0X128, info.FeeGrowthOutside0X128)
 	info.FeeGrowthOutside1X128.Sub(feeGrowthGlobal1X128, info.FeeGrowthOutside1X128)
 	liquidityNet = info.LiquidityNet
@@ -93,13 +108,13 @@ func makeTick(tick, tickCurrent int, liquidityNet, liquidityGross, feeGrowthOuts
 
 }
 
-func (t *TickData) ClearTick(index int) {
+func (t *TickData) ClearTick(index int) error {
 	i, found := t.binarySearch2(index)
-	if found {
-		t.ticks = append(t.ticks[:i], t.ticks[i+1:]...)
-	} else {
-		fmt.Println("ClearTick: tick not found")
+	if !found {
+		return fmt.Errorf("%w: %d", ErrTickNotFound, index)
 	}
+	t.ticks = append(t.ticks[:i], t.ticks[i+1:]...)
+	return nil
 }
 
 func (t *TickData) UpdateTick(index, tickCurrent int, liquidityDelta, feeGrowthGlobal0X128, feeGrowthGlobal1X128 *ui.Int, upper bool) bool {
@@ -138,9 +153,15 @@ func (t *TickData) UpdateTick(index, tickCurrent int, liquidityDelta, feeGrowthG
 
 }
 
-func (t *TickData) GetFeeGrowthInside(tickLower, tickUpper, tickCurrent int, feeGrowthGlobal0X128, feeGrowthGlobal1X128 *ui.Int) (feeGrowthInside0X128, feeGrowthInside1X128 *ui.Int) {
-	lower := t.GetTick(tickLower)
-	upper := t.GetTick(tickUpper)
+func (t *TickData) GetFeeGrowthInside(tickLower, tickUpper, tickCurrent int, feeGrowthGlobal0X128, feeGrowthGlobal1X128 *ui.Int) (feeGrowthInside0X128, feeGrowthInside1X128 *ui.Int, err error) {
+	lower, err := t.GetTick(tickLower)
+	if err != nil {
+		return nil, nil, err
+	}
+	upper, err := t.GetTick(tickUpper)
+	if err != nil {
+		return nil, nil, err
+	}
 	var feeGrowthBelow0X128, feeGrowthBelow1X128 *ui.Int
 	if tickCurrent >= tickLower {
 		feeGrowthBelow0X128 = new(ui.Int).Set(lower.FeeGrowthOutside0X128)
@@ -166,6 +187,10 @@ func (t *TickData) GetFeeGrowthInside(tickLower, tickUpper, tickCurrent int, fee
 
 func (t *TickData) NextInitializedTickWithinOneWord(tick int, lte bool) (int, bool) {
 	compressed := tick / t.tickSpacing
+	// Round towards negative infinity like the contract
+	if tick < 0 && tick%t.tickSpacing != 0 {
+		compressed--
+	}
 	if lte {
 		wordPos := compressed >> 8
 		minimum := (wordPos << 8) * t.tickSpacing
@@ -188,8 +213,8 @@ func (t *TickData) NextInitializedTickWithinOneWord(tick int, lte bool) (int, bo
 	}
 }
 
+// binarySearch returns the index of the largest tick at or below tick, which must not be below the smallest
 func (t *TickData) binarySearch(tick int) int {
-	invariant.Invariant(!t.isBelowSmallest(tick), "binarySearch: tick below smallest")
 	l := 0
 	r := len(t.ticks) - 1
 	var i int
@@ -242,16 +267,16 @@ func (t *TickData) binarySearch2(tick int) (int, bool) {
 
 }
 
+// nextInitializedTick is only called by NextInitializedTickWithinOneWord,
+// which checks that there is an initialized tick in the direction
 func (t *TickData) nextInitializedTick(tick int, lte bool) Tick {
 	if lte {
-		invariant.Invariant(!t.isBelowSmallest(tick), "Below smallest Tick")
 		if t.isAtOrAboveLargest(tick) {
 			return t.ticks[len(t.ticks)-1]
 		}
 		index := t.binarySearch(tick)
 		return t.ticks[index]
 	} else {
-		invariant.Invariant(!t.isAtOrAboveLargest(tick), "AtOrAboveLargest Tick")
 		if t.isBelowSmallest(tick) {
 			return t.ticks[0]
 		}
@@ -0,0 +1,43 @@
+package tickdata
+
+import (
+	"testing"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestNextInitializedTickWithinOneWord(t *testing.T) {
+	// With a tick spacing of 10 the words are 2560 ticks wide, [-5120, -2560) is the word below [-2560, 0)
+	empty := NewTickData(10)
+	data := NewTickData(10)
+	for _, tick := range []int{-2600, -2540} {
+		data.UpdateTick(tick, 0, ui.NewInt(1000), new(ui.Int), new(ui.Int), tick == -2540)
+	}
+	for _, test := range []struct {
+		data        *TickData
+		tick        int
+		lte         bool
+		next        int
+		initialized bool
+	}{
+		// Negative ticks that are not multiples of the spacing round down like the contract
+		{empty, -5, true, -2560, false},
+		{empty, -5, false, 2550, false},
+		{empty, -2565, true, -5120, false},
+		{empty, -2565, false, -10, false},
+		{data, -2565, true, -2600, true},
+		{data, -2565, false, -2540, true},
+		{data, -2595, true, -2600, true},
+		{data, -2605, true, -5120, false},
+		{data, -2545, false, -2540, true},
+		// Multiples of the spacing
+		{data, -2560, true, -2560, false},
+		{data, -2600, true, -2600, true},
+		{data, -2600, false, -2570, false},
+		{empty, 15, true, 0, false},
+	} {
+		next, initialized := test.data.NextInitializedTickWithinOneWord(test.tick, test.lte)
+		if next != test.next || initialized != test.initialized {
+			t.Errorf("NextInitializedTickWithinOneWord(%d, %v) = %d, %v, want %d, %v", test.tick, test.lte, next, initialized, test.next, test.initialized)
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package tickmath
 
 import (
+	"errors"
 	"math"
 	"math/big"
 	cons "uniswap-simulator/lib/constants"
-	"uniswap-simulator/lib/invariant"
 	ui "uniswap-simulator/uint256"
 )
 
@@ -14,6 +14,11 @@ const (
 	TotalTicks int = MaxTick - MinTick + 1
 )
 
+var (
+	ErrTickOutOfRange      = errors.New("tick out of range")
+	ErrSqrtRatioOutOfRange = errors.New("sqrtRatioX96 must be between MinSqrtRatio and MaxSqrtRatio")
+)
+
 var (
 	Q32             = ui.NewInt(1 << 32)
 	MinSqrtRatio    = ui.NewInt(4295128739) // The sqrt ratio corresponding to the minimum tick that could be used on any pool.
@@ -31,7 +36,8 @@ func initTickMath() *TickMath {
 	t := new(TickMath)
 
 	for i := 0; i < TotalTicks; i++ {
-		t.ticks[i] = getSqrtRatioAtTick(i + MinTick)
+		// All ticks of the table are in range
+		t.ticks[i], _ = getSqrtRatioAtTick(i + MinTick)
 	}
 	return t
 }
@@ -57,8 +63,10 @@ func Floor(ix, iunit int) int {
 func (t *TickMath) GetSqrtRatioAtTick(tick int) *ui.Int {
 	return new(ui.Int).Set(t.ticks[tick+MaxTick])
 }
-func (t *TickMath) GetTickAtSqrtRatio(sqrtRatioX96 *ui.Int) int {
-	invariant.Invariant(sqrtRatioX96.Cmp(MinSqrtRatio) >= 0 && sqrtRatioX96.Cmp(MaxSqrtRatio) < 0, "sqrtRatioX96 must be between MinSqrtRatio and MaxSqrtRatio")
+func (t *TickMath) GetTickAtSqrtRatio(sqrtRatioX96 *ui.Int) (int, error) {
+	if sqrtRatioX96.Cmp(MinSqrtRatio) < 0 || sqrtRatioX96.Cmp(MaxSqrtRatio) >= 0 {
+		return 0, ErrSqrtRatioOutOfRange
+	}
 	l := 0
 	r := TotalTicks - 1
 	var mid int
@@ -71,19 +79,21 @@ func (t *TickMath) GetTickAtSqrtRatio(sqrtRatioX96 *ui.Int) int {
 			l = mid
 		}
 	}
-	return l + MinTick
+	return l + MinTick, nil
 }
 
 // GetSqrtRatioAtTick
 // Returns the sqrt ratio as a Q64.96 for the given tick. The sqrt ratio is computed as sqrt(1.0001)^tick
 // @param tick the tick for which to compute the sqrt ratio
 //
-func getSqrtRatioAtTick(tick int) *ui.Int {
+func getSqrtRatioAtTick(tick int) (*ui.Int, error) {
 	absTick := tick
 	if tick < 0 {
 		absTick = -tick
 	}
-	invariant.Invariant(absTick <= MaxTick, "tick out of range")
+	if absTick > MaxTick {
+		return nil, ErrTickOutOfRange
+	}
 	var ratio *ui.Int
 	if absTick&0x1 != 0 {
 		ratio, _ = ui.FromHex("0xfffcb933bd6fad37aa2d162d1a594001")
@@ -153,14 +163,14 @@ func getSqrtRatioAtTick(tick int) *ui.Int {
 
 	// back to Q96
 	if new(ui.Int).SMod(ratio, Q32).Sign() > 0 {
-		return new(ui.Int).Add(new(ui.Int).Div(ratio, Q32), cons.One)
+		return new(ui.Int).Add(new(ui.Int).Div(ratio, Q32), cons.One), nil
 	} else {
-		return new(ui.Int).Div(ratio, Q32)
+		return new(ui.Int).Div(ratio, Q32), nil
 	}
 }
 
 // GetTickAtSqrtRatio /**
-func getTickAtSqrtRatio(sqrtRatioX96 *ui.Int) int {
+func getTickAtSqrtRatio(sqrtRatioX96 *ui.Int) (int, error) {
 	sqrtRatioX128 := new(ui.Int).Lsh(sqrtRatioX96, 32)
 	msb := MostSignificantBit(sqrtRatioX128)
 	var r *ui.Int
@@ -188,14 +198,17 @@ func getTickAtSqrtRatio(sqrtRatioX96 *ui.Int) int {
 	tickHigh := int(new(ui.Int).Rsh(new(ui.Int).Add(logSqrt10001, magicTickHigh), 128).Uint64())
 
 	if tickLow == tickHigh {
-		return tickLow
+		return tickLow, nil
 	}
 
-	sqrtRatio := getSqrtRatioAtTick(tickHigh)
+	sqrtRatio, err := getSqrtRatioAtTick(tickHigh)
+	if err != nil {
+		return 0, err
+	}
 	if sqrtRatio.Cmp(sqrtRatioX96) <= 0 {
-		return tickHigh
+		return tickHigh, nil
 	} else {
-		return tickLow
+		return tickLow, nil
 	}
 }
 
@@ -66,3 +66,15 @@ func TestUpperBound(t *testing.T) {
 		})
 	}
 }
+
+func TestOutOfRange(t *testing.T) {
+	if _, err := TM.GetTickAtSqrtRatio(MaxSqrtRatio); err != ErrSqrtRatioOutOfRange {
+		t.Errorf("MaxSqrtRatio: %v", err)
+	}
+	if tick, err := TM.GetTickAtSqrtRatio(MinSqrtRatio); err != nil || tick != MinTick {
+		t.Errorf("MinSqrtRatio: tick %d, %v", tick, err)
+	}
+	if _, err := getSqrtRatioAtTick(MaxTick + 1); err != ErrTickOutOfRange {
+		t.Errorf("MaxTick + 1: %v", err)
+	}
+}
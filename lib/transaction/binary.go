@@ -0,0 +1,324 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	ui "uniswap-simulator/uint256"
+)
+
+/* Binary event format
+Header: magic "USIM" and a version byte.
+Version 2 added flagExactOutput and flagLiquidity, version 1 files are still read but must not set them.
+Then one record per tag byte:
+	tagString: uvarint length and the bytes of the next string table entry
+	tagMint, tagBurn, tagSwap, tagFlash (| flagUseX96 | flagExactOutput | flagLiquidity):
+		varint timestamp delta to the previous event, uvarint string table index of the ID
+		Mint, Burn: amount, amount0, amount1 as 32 byte big endian, varint tickLower, varint tickUpper
+		Swap:       amount0, amount1, sqrtPriceX96 as 32 byte big endian, varint tick,
+		            with flagLiquidity the liquidity as 32 byte big endian
+		Flash:      amount0, amount1 as 32 byte big endian
+Signed amounts are stored in two's complement like ui.Int.
+*/
+
+const (
+	binaryVersion = 2
+
+	tagString  = 0x00
+	tagMint    = 0x01
+	tagBurn    = 0x02
+	tagSwap    = 0x03
+	tagFlash   = 0x04
+	tagType    = 0x0f
+	flagUseX96 = 0x10
+	// Since version 2
+	flagExactOutput = 0x20
+	flagLiquidity   = 0x40
+)
+
+var binaryMagic = []byte("USIM")
+
+var ErrNotBinary = errors.New("not a binary event file")
+
+var tags = map[string]byte{"Mint": tagMint, "Burn": tagBurn, "Swap": tagSwap, "Flash": tagFlash}
+var types = map[byte]string{tagMint: "Mint", tagBurn: "Burn", tagSwap: "Swap", tagFlash: "Flash"}
+
+type BinaryEncoder struct {
+	writer        *bufio.Writer
+	strings       map[string]uint64
+	lastTimestamp int
+	buf           []byte
+}
+
+func NewBinaryEncoder(w io.Writer) (*BinaryEncoder, error) {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.Write(binaryMagic); err != nil {
+		return nil, err
+	}
+	if err := writer.WriteByte(binaryVersion); err != nil {
+		return nil, err
+	}
+	return &BinaryEncoder{
+		writer:  writer,
+		strings: make(map[string]uint64),
+		buf:     make([]byte, 0, 4*32+4*binary.MaxVarintLen64),
+	}, nil
+}
+
+func (e *BinaryEncoder) Encode(t Transaction) error {
+	tag, ok := tags[t.Type]
+	if !ok {
+		return fmt.Errorf("unknown transaction type %q", t.Type)
+	}
+	index, ok := e.strings[t.ID]
+	if !ok {
+		index = uint64(len(e.strings))
+		e.strings[t.ID] = index
+		buf := append(e.buf[:0], tagString)
+		buf = appendUvarint(buf, uint64(len(t.ID)))
+		buf = append(buf, t.ID...)
+		if _, err := e.writer.Write(buf); err != nil {
+			return err
+		}
+	}
+	if t.UseX96 {
+		tag |= flagUseX96
+	}
+	if t.ExactOutput {
+		tag |= flagExactOutput
+	}
+	if t.Type == "Swap" && t.Liquidity != nil {
+		tag |= flagLiquidity
+	}
+
+	buf := append(e.buf[:0], tag)
+	buf = appendVarint(buf, int64(t.Timestamp-e.lastTimestamp))
+	buf = appendUvarint(buf, index)
+	switch tag & tagType {
+	case tagMint, tagBurn:
+		buf = appendUint256(buf, t.Amount)
+		buf = appendUint256(buf, t.Amount0)
+		buf = appendUint256(buf, t.Amount1)
+		buf = appendVarint(buf, int64(t.TickLower))
+		buf = appendVarint(buf, int64(t.TickUpper))
+	case tagSwap:
+		buf = appendUint256(buf, t.Amount0)
+		buf = appendUint256(buf, t.Amount1)
+		buf = appendUint256(buf, t.SqrtPriceX96)
+		buf = appendVarint(buf, int64(t.Tick))
+		if tag&flagLiquidity != 0 {
+			buf = appendUint256(buf, t.Liquidity)
+		}
+	case tagFlash:
+		buf = appendUint256(buf, t.Amount0)
+		buf = appendUint256(buf, t.Amount1)
+	}
+	e.lastTimestamp = t.Timestamp
+	_, err := e.writer.Write(buf)
+	return err
+}
+
+func (e *BinaryEncoder) Flush() error {
+	return e.writer.Flush()
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], x)]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutVarint(b[:], x)]...)
+}
+
+func appendUint256(buf []byte, x *ui.Int) []byte {
+	if x == nil {
+		x = new(ui.Int)
+	}
+	b := x.Bytes32()
+	return append(buf, b[:]...)
+}
+
+type BinaryDecoder struct {
+	reader        *bufio.Reader
+	version       byte
+	strings       []string
+	lastTimestamp int
+	buf           [32]byte
+}
+
+func NewBinaryDecoder(r io.Reader) (*BinaryDecoder, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil || !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		return nil, ErrNotBinary
+	}
+	version := header[len(binaryMagic)]
+	if version < 1 || version > binaryVersion {
+		return nil, fmt.Errorf("unsupported binary event format version %d", version)
+	}
+	return &BinaryDecoder{reader: reader, version: version}, nil
+}
+
+func (d *BinaryDecoder) Next() (Transaction, error) {
+	for {
+		tag, err := d.reader.ReadByte()
+		if err != nil {
+			return Transaction{}, err
+		}
+		if tag != tagString {
+			t, err := d.readTransaction(tag)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return t, err
+		}
+		if err := d.readString(); err != nil {
+			return Transaction{}, err
+		}
+	}
+}
+
+func (d *BinaryDecoder) readString() error {
+	length, err := binary.ReadUvarint(d.reader)
+	if err != nil {
+		return err
+	}
+	s := make([]byte, length)
+	if _, err := io.ReadFull(d.reader, s); err != nil {
+		return err
+	}
+	d.strings = append(d.strings, string(s))
+	return nil
+}
+
+func (d *BinaryDecoder) readTransaction(tag byte) (t Transaction, err error) {
+	var ok bool
+	if t.Type, ok = types[tag&tagType]; !ok {
+		return t, fmt.Errorf("unknown event tag %#x", tag)
+	}
+	if d.version < 2 && tag&(flagExactOutput|flagLiquidity) != 0 {
+		return t, fmt.Errorf("event tag %#x in a version %d file", tag, d.version)
+	}
+	t.UseX96 = tag&flagUseX96 != 0
+	t.ExactOutput = tag&flagExactOutput != 0
+
+	delta, err := binary.ReadVarint(d.reader)
+	if err != nil {
+		return
+	}
+	d.lastTimestamp += int(delta)
+	t.Timestamp = d.lastTimestamp
+	index, err := binary.ReadUvarint(d.reader)
+	if err != nil {
+		return
+	}
+	if index >= uint64(len(d.strings)) {
+		return t, fmt.Errorf("string index %d out of range", index)
+	}
+	t.ID = d.strings[index]
+
+	t.Amount, t.SqrtPriceX96 = new(ui.Int), new(ui.Int)
+	switch tag & tagType {
+	case tagMint, tagBurn:
+		if t.Amount, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.Amount0, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.Amount1, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.TickLower, err = d.readInt(); err != nil {
+			return
+		}
+		t.TickUpper, err = d.readInt()
+	case tagSwap:
+		if t.Amount0, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.Amount1, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.SqrtPriceX96, err = d.readUint256(); err != nil {
+			return
+		}
+		if t.Tick, err = d.readInt(); err != nil {
+			return
+		}
+		if tag&flagLiquidity != 0 {
+			t.Liquidity, err = d.readUint256()
+		}
+	case tagFlash:
+		if t.Amount0, err = d.readUint256(); err != nil {
+			return
+		}
+		t.Amount1, err = d.readUint256()
+	}
+	return
+}
+
+func (d *BinaryDecoder) readUint256() (*ui.Int, error) {
+	if _, err := io.ReadFull(d.reader, d.buf[:]); err != nil {
+		return nil, err
+	}
+	return new(ui.Int).SetBytes32(d.buf[:]), nil
+}
+
+func (d *BinaryDecoder) readInt() (int, error) {
+	x, err := binary.ReadVarint(d.reader)
+	return int(x), err
+}
+
+func (d *BinaryDecoder) Close() error {
+	return nil
+}
+
+// BinaryFile streams a binary event file from disk on every Open
+type BinaryFile string
+
+func (f BinaryFile) Open() (Iterator, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := NewBinaryDecoder(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+	return &binaryFileIterator{decoder, file}, nil
+}
+
+type binaryFileIterator struct {
+	*BinaryDecoder
+	file *os.File
+}
+
+func (it *binaryFileIterator) Close() error {
+	return it.file.Close()
+}
+
+// OpenFile returns the Source for a binary event file or a JSON file depending on its header
+func OpenFile(filepath string) (Source, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header := make([]byte, len(binaryMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(header[:n], binaryMagic) {
+		return BinaryFile(filepath), nil
+	}
+	return File(filepath), nil
+}
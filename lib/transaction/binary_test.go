@@ -0,0 +1,116 @@
+package transaction
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	ui "uniswap-simulator/uint256"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	input := `{"type":"Mint","id":"a","timestamp":1620000000,"amount0":"10","amount1":"20","amount":"5","tickLower":-887270,"tickUpper":887270}
+{"type":"Swap","id":"b","timestamp":1620000012,"amount0":"-3","amount1":"4","sqrtPriceX96":"1350174849792634181862360983626536","tick":195000,"useX96":"true","liquidity":"30215871190049079976"}
+{"type":"Swap","id":"a","timestamp":1620000012,"amount0":"57896044618658097711785492504343953926634992332820282019728792003956564819967","amount1":"-57896044618658097711785492504343953926634992332820282019728792003956564819968","sqrtPriceX96":"4295128740","tick":-887272,"useX96":"false","exactOutput":"true"}
+{"type":"Flash","id":"c","timestamp":1620000005,"amount0":"0","amount1":"115792089237316195423570985008687907853269984665640564039457"}
+{"type":"Burn","id":"b","timestamp":1620003600,"amount0":"1","amount1":"0","amount":"340282366920938463463374607431768211455","tickLower":10,"tickUpper":20}
+`
+	decoder, err := NewDecoder(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	encoder, err := NewBinaryEncoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for {
+		trans, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encoder.Encode(trans); err != nil {
+			t.Fatal(err)
+		}
+		j, _ := trans.MarshalJSON()
+		want = append(want, string(j))
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	binaryDecoder, err := NewBinaryDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		trans, err := binaryDecoder.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Fatalf("decoded %d transactions, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := trans.MarshalJSON()
+		if string(got) != want[i] {
+			t.Errorf("transaction %d:\n got %s\nwant %s", i, got, want[i])
+		}
+	}
+}
+
+func TestBinaryDecoderErrors(t *testing.T) {
+	if _, err := NewBinaryDecoder(strings.NewReader(`[{"type":"Mint"}]`)); err != ErrNotBinary {
+		t.Errorf("JSON input: got %v, want ErrNotBinary", err)
+	}
+	var buf bytes.Buffer
+	encoder, _ := NewBinaryEncoder(&buf)
+	if err := encoder.Encode(Transaction{Type: "Collect"}); err == nil {
+		t.Error("encoded unknown transaction type")
+	}
+	encoder.Encode(Transaction{Type: "Flash", ID: "a", Timestamp: 1})
+	encoder.Flush()
+	decoder, _ := NewBinaryDecoder(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if _, err := decoder.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated input: got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := NewBinaryDecoder(bytes.NewReader([]byte("USIM\x03"))); err == nil {
+		t.Error("decoded version 3")
+	}
+}
+
+func TestBinaryVersion1(t *testing.T) {
+	// Version 1 files are read unless an event has a flag of version 2
+	for _, test := range []struct {
+		trans Transaction
+		err   bool
+	}{
+		{Transaction{Type: "Swap", ID: "a", Timestamp: 1, UseX96: true}, false},
+		{Transaction{Type: "Swap", ID: "a", Timestamp: 1, ExactOutput: true}, true},
+		{Transaction{Type: "Swap", ID: "a", Timestamp: 1, Liquidity: ui.NewInt(5)}, true},
+	} {
+		var buf bytes.Buffer
+		encoder, _ := NewBinaryEncoder(&buf)
+		encoder.Encode(test.trans)
+		encoder.Flush()
+		data := buf.Bytes()
+		data[len(binaryMagic)] = 1
+		decoder, err := NewBinaryDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans, err := decoder.Next()
+		if (err != nil) != test.err {
+			t.Errorf("%+v: error %v", test.trans, err)
+		}
+		if err == nil && (trans.Type != "Swap" || trans.Timestamp != 1 || !trans.UseX96) {
+			t.Errorf("decoded %+v", trans)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"io"
+)
+
+// Merge replays the histories of several pools as one, ordered by timestamp.
+// The Pool of every transaction is set to the index of its source.
+// Transactions with the same timestamp are yielded in the order of the sources.
+func Merge(sources ...Source) Source {
+	return merged(sources)
+}
+
+type merged []Source
+
+func (m merged) Open() (Iterator, error) {
+	it := &mergeIterator{
+		iterators: make([]Iterator, 0, len(m)),
+		heads:     make([]Transaction, len(m)),
+		done:      make([]bool, len(m)),
+	}
+	for i, source := range m {
+		iterator, err := source.Open()
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		it.iterators = append(it.iterators, iterator)
+		if err := it.advance(i); err != nil {
+			it.Close()
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// mergeIterator keeps the next transaction of every source.
+// The amount of pools is small, so the earliest is found by a linear scan.
+type mergeIterator struct {
+	iterators []Iterator
+	heads     []Transaction
+	done      []bool
+}
+
+func (it *mergeIterator) advance(i int) error {
+	trans, err := it.iterators[i].Next()
+	if err == io.EOF {
+		it.done[i] = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	trans.Pool = i
+	it.heads[i] = trans
+	return nil
+}
+
+func (it *mergeIterator) Next() (Transaction, error) {
+	next := -1
+	for i := range it.iterators {
+		if it.done[i] {
+			continue
+		}
+		if next < 0 || it.heads[i].Timestamp < it.heads[next].Timestamp {
+			next = i
+		}
+	}
+	if next < 0 {
+		return Transaction{}, io.EOF
+	}
+	trans := it.heads[next]
+	if err := it.advance(next); err != nil {
+		return Transaction{}, err
+	}
+	return trans, nil
+}
+
+func (it *mergeIterator) Close() error {
+	var err error
+	for _, iterator := range it.iterators {
+		if closeErr := iterator.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
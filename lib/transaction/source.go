@@ -0,0 +1,201 @@
+package transaction
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	ui "uniswap-simulator/uint256"
+)
+
+// Iterator yields transactions in the order of the history.
+// Next returns io.EOF after the last transaction.
+type Iterator interface {
+	Next() (Transaction, error)
+	Close() error
+}
+
+// Source is a replayable transaction history. Every Open starts from the first transaction.
+type Source interface {
+	Open() (Iterator, error)
+}
+
+// Slice is an in memory Source
+type Slice []Transaction
+
+func (s Slice) Open() (Iterator, error) {
+	return &sliceIterator{s, 0}, nil
+}
+
+type sliceIterator struct {
+	transactions []Transaction
+	index        int
+}
+
+func (it *sliceIterator) Next() (Transaction, error) {
+	if it.index >= len(it.transactions) {
+		return Transaction{}, io.EOF
+	}
+	it.index++
+	return it.transactions[it.index-1], nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// File streams a JSON file of TransactionInput from disk on every Open.
+// The file is either a JSON array or one JSON object per line.
+type File string
+
+func (f File) Open() (Iterator, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := NewDecoder(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+	return &fileIterator{decoder, file}, nil
+}
+
+type fileIterator struct {
+	*Decoder
+	file *os.File
+}
+
+func (it *fileIterator) Close() error {
+	return it.file.Close()
+}
+
+// Decoder reads TransactionInput one at a time from a JSON array or JSON lines
+type Decoder struct {
+	decoder *json.Decoder
+	array   bool
+}
+
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	reader := bufio.NewReader(r)
+	array := false
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			continue
+		}
+		array = b == '['
+		if err := reader.UnreadByte(); err != nil {
+			return nil, err
+		}
+		break
+	}
+	decoder := json.NewDecoder(reader)
+	if array {
+		// Consume the opening bracket so Decode reads one element at a time
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return &Decoder{decoder, array}, nil
+}
+
+func (d *Decoder) Next() (Transaction, error) {
+	if d.array && !d.decoder.More() {
+		return Transaction{}, io.EOF
+	}
+	var input TransactionInput
+	if err := d.decoder.Decode(&input); err != nil {
+		return Transaction{}, err
+	}
+	return input.Transaction(), nil
+}
+
+func (d *Decoder) Close() error {
+	return nil
+}
+
+// Load reads a whole Source into memory
+func Load(source Source) (Slice, error) {
+	it, err := source.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var transactions Slice
+	for {
+		trans, err := it.Next()
+		if err == io.EOF {
+			return transactions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, trans)
+	}
+}
+
+// Bounds returns the amount of transactions and the first and last timestamp of a Source
+func Bounds(source Source) (count, firstTimestamp, lastTimestamp int, err error) {
+	it, err := source.Open()
+	if err != nil {
+		return
+	}
+	defer it.Close()
+	for {
+		var trans Transaction
+		trans, err = it.Next()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		if count == 0 {
+			firstTimestamp = trans.Timestamp
+		}
+		lastTimestamp = trans.Timestamp
+		count++
+	}
+}
+
+func (t TransactionInput) Transaction() Transaction {
+	useX96, _ := strconv.ParseBool(t.UseX96)
+	exactOutput, _ := strconv.ParseBool(t.ExactOutput)
+	var liquidity *ui.Int
+	if t.Liquidity != "" {
+		liquidity = stringToUint256(t.Liquidity)
+	}
+	return Transaction{
+		Type:         t.Type,
+		Amount:       stringToUint256(t.Amount),
+		Amount0:      stringToUint256(t.Amount0),
+		Amount1:      stringToUint256(t.Amount1),
+		ID:           t.ID,
+		SqrtPriceX96: stringToUint256(t.SqrtPriceX96),
+		Tick:         t.Tick,
+		TickLower:    t.TickLower,
+		TickUpper:    t.TickUpper,
+		Timestamp:    t.Timestamp,
+		UseX96:       useX96,
+		ExactOutput:  exactOutput,
+		Liquidity:    liquidity,
+	}
+}
+
+func stringToUint256(amount string) *ui.Int {
+	bigint := new(big.Int)
+	bigint.SetString(amount, 10)
+	uint256, _ := ui.FromBig(bigint)
+	return uint256
+}
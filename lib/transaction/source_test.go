@@ -0,0 +1,63 @@
+package transaction
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	array := ` [{"type":"Mint","id":"a","timestamp":1,"amount0":"10","amount1":"20","amount":"5","tickLower":-10,"tickUpper":10},
+		{"type":"Swap","id":"b","timestamp":2,"amount0":"-3","amount1":"4","sqrtPriceX96":"79228162514264337593543950336","tick":0,"useX96":"true"}]`
+	lines := `{"type":"Mint","id":"a","timestamp":1,"amount0":"10","amount1":"20","amount":"5","tickLower":-10,"tickUpper":10}
+{"type":"Swap","id":"b","timestamp":2,"amount0":"-3","amount1":"4","sqrtPriceX96":"79228162514264337593543950336","tick":0,"useX96":"true"}
+`
+	for name, input := range map[string]string{"array": array, "lines": lines} {
+		t.Run(name, func(t *testing.T) {
+			decoder, err := NewDecoder(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var transactions Slice
+			for {
+				trans, err := decoder.Next()
+				if err != nil {
+					break
+				}
+				transactions = append(transactions, trans)
+			}
+			if len(transactions) != 2 {
+				t.Fatalf("decoded %d transactions, want 2", len(transactions))
+			}
+			mint, swap := transactions[0], transactions[1]
+			if mint.TickLower != -10 || mint.Amount.Uint64() != 5 {
+				t.Errorf("mint decoded as %+v", mint)
+			}
+			if swap.Amount0.SToBig().Int64() != -3 || !swap.UseX96 || swap.Timestamp != 2 {
+				t.Errorf("swap decoded as %+v", swap)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	at := func(timestamps ...int) Slice {
+		var transactions Slice
+		for _, timestamp := range timestamps {
+			transactions = append(transactions, Transaction{Type: "Swap", Timestamp: timestamp})
+		}
+		return transactions
+	}
+	transactions, err := Load(Merge(at(1, 5, 9), at(), at(2, 5, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ timestamp, pool int }{{1, 0}, {2, 2}, {5, 0}, {5, 2}, {9, 0}, {10, 2}}
+	if len(transactions) != len(want) {
+		t.Fatalf("merged %d transactions, want %d", len(transactions), len(want))
+	}
+	for i, w := range want {
+		if transactions[i].Timestamp != w.timestamp || transactions[i].Pool != w.pool {
+			t.Errorf("transaction %d at %d of pool %d, want %d of pool %d", i, transactions[i].Timestamp, transactions[i].Pool, w.timestamp, w.pool)
+		}
+	}
+}
@@ -18,6 +18,8 @@ type TransactionInput struct {
 	TickLower    int    `json:"tickLower,omitempty"`
 	TickUpper    int    `json:"tickUpper,omitempty"`
 	UseX96       string `json:"useX96,omitempty"`
+	ExactOutput  string `json:"exactOutput,omitempty"`
+	Liquidity    string `json:"liquidity,omitempty"`
 }
 
 type Transaction struct {
@@ -31,12 +33,23 @@ type Transaction struct {
 	TickLower    int
 	TickUpper    int
 	Timestamp    int
-	UseX96       bool
+	// UseX96 replays a swap with SqrtPriceX96 as its price limit
+	UseX96 bool
+	// ExactOutput replays a swap for the exact amount out instead of the exact amount in
+	ExactOutput bool
+	// Liquidity is the in range liquidity of the pool after a swap, nil if not recorded
+	Liquidity *ui.Int
+	// Pool is the index of the source in a Merge the transaction comes from, 0 for a single source
+	Pool int
 }
 
 func (t Transaction) MarshalJSON() ([]byte, error) {
 	switch t.Type {
 	case "Swap":
+		var liquidity string
+		if t.Liquidity != nil {
+			liquidity = t.Liquidity.ToBig().String()
+		}
 		return json.Marshal(&TransactionInput{
 			Type:         t.Type,
 			Amount0:      t.Amount0.SToBig().String(),
@@ -46,6 +59,8 @@ func (t Transaction) MarshalJSON() ([]byte, error) {
 			Tick:         t.Tick,
 			Timestamp:    t.Timestamp,
 			UseX96:       strconv.FormatBool(t.UseX96),
+			ExactOutput:  strconv.FormatBool(t.ExactOutput),
+			Liquidity:    liquidity,
 		})
 	case "Mint", "Burn":
 		return json.Marshal(&TransactionInput{
@@ -0,0 +1,154 @@
+package verify
+
+import (
+	"io"
+	"math/big"
+	"strconv"
+	"uniswap-simulator/lib/executor"
+	ppool "uniswap-simulator/lib/pool"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+// Owner of all positions of the history, whose owners are not in the transactions
+const Owner = "history"
+
+// Tolerance of the comparison of a replayed value with the recorded one.
+// An amount, price or liquidity diverges if it differs by more than Units and by more than Relative of the recorded value.
+// The zero Tolerance only accepts exact matches.
+type Tolerance struct {
+	Relative float64
+	Units    uint64
+	Ticks    int
+}
+
+// Field is a value of an event the replay did not reproduce
+type Field struct {
+	Name     string `json:"name"`
+	Recorded string `json:"recorded"`
+	Replayed string `json:"replayed"`
+}
+
+// Divergence is a transaction whose replay differs from the recorded event
+type Divergence struct {
+	// Index of the transaction in the history
+	Index     int     `json:"index"`
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	Timestamp int     `json:"timestamp"`
+	Fields    []Field `json:"fields,omitempty"`
+	// Error of a transaction the pool could not replay
+	Error string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Transactions int `json:"transactions"`
+	// Divergent counts the divergent transactions, Divergences holds the first MaxDivergences of them
+	Divergent   int          `json:"divergent"`
+	First       *Divergence  `json:"first,omitempty"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// Verifier replays a history without strategies and compares the pool with the recorded events:
+// the amounts of mints, burns and swaps and the price, tick and, if recorded, the liquidity after swaps.
+type Verifier struct {
+	Tolerance Tolerance
+	// MaxDivergences limits the divergences kept in the report, 0 keeps all
+	MaxDivergences int
+}
+
+// Run replays transactions against pool. Only errors reading the history are returned,
+// transactions the pool rejects are divergences.
+func (v Verifier) Run(pool *ppool.Pool, transactions ent.Source) (*Report, error) {
+	it, err := transactions.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	report := &Report{Divergences: make([]Divergence, 0)}
+	for ; ; report.Transactions++ {
+		trans, err := it.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pool.Timestamp = trans.Timestamp
+		divergence := Divergence{Index: report.Transactions, ID: trans.ID, Type: trans.Type, Timestamp: trans.Timestamp}
+		if err := v.replay(pool, trans, &divergence); err != nil {
+			divergence.Error = err.Error()
+		}
+		if divergence.Error == "" && len(divergence.Fields) == 0 {
+			continue
+		}
+		if report.Divergent == 0 {
+			first := divergence
+			report.First = &first
+		}
+		report.Divergent++
+		if v.MaxDivergences == 0 || len(report.Divergences) < v.MaxDivergences {
+			report.Divergences = append(report.Divergences, divergence)
+		}
+	}
+}
+
+// replay applies trans to pool and adds the values that diverge to divergence
+func (v Verifier) replay(pool *ppool.Pool, trans ent.Transaction, divergence *Divergence) error {
+	var amount0, amount1 *ui.Int
+	var err error
+	switch trans.Type {
+	case "Mint":
+		amount0, amount1, err = pool.Mint(Owner, trans.TickLower, trans.TickUpper, trans.Amount)
+	case "Burn":
+		amount0, amount1, err = pool.Burn(Owner, trans.TickLower, trans.TickUpper, trans.Amount)
+	case "Swap":
+		amount0, amount1, err = executor.Swap(pool, trans)
+	case "Flash":
+		return pool.Flash(trans.Amount0, trans.Amount1)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	v.compare(divergence, "amount0", trans.Amount0, amount0)
+	v.compare(divergence, "amount1", trans.Amount1, amount1)
+	if trans.Type != "Swap" {
+		return nil
+	}
+	v.compare(divergence, "sqrtPriceX96", trans.SqrtPriceX96, pool.SqrtRatioX96)
+	if diff := trans.Tick - pool.TickCurrent; diff > v.Tolerance.Ticks || -diff > v.Tolerance.Ticks {
+		divergence.Fields = append(divergence.Fields, Field{"tick", strconv.Itoa(trans.Tick), strconv.Itoa(pool.TickCurrent)})
+	}
+	if trans.Liquidity != nil {
+		v.compare(divergence, "liquidity", trans.Liquidity, pool.Liquidity)
+	}
+	return nil
+}
+
+// compare adds the field to divergence if replayed is not within the tolerance of recorded.
+// Both are signed like the amounts of the events.
+func (v Verifier) compare(divergence *Divergence, name string, recorded, replayed *ui.Int) {
+	if recorded.Eq(replayed) {
+		return
+	}
+	diff := new(ui.Int).Sub(recorded, replayed)
+	if diff.Sign() < 0 {
+		diff.Neg(diff)
+	}
+	if !diff.Gt(ui.NewInt(v.Tolerance.Units)) {
+		return
+	}
+	magnitude := recorded.Clone()
+	if magnitude.Sign() < 0 {
+		magnitude.Neg(magnitude)
+	}
+	if !magnitude.IsZero() {
+		relative := new(big.Float).Quo(new(big.Float).SetInt(diff.ToBig()), new(big.Float).SetInt(magnitude.ToBig()))
+		if relative.Cmp(big.NewFloat(v.Tolerance.Relative)) <= 0 {
+			return
+		}
+	}
+	divergence.Fields = append(divergence.Fields, Field{name, recorded.SToBig().String(), replayed.SToBig().String()})
+}
@@ -0,0 +1,105 @@
+package verify
+
+import (
+	"math/big"
+	"testing"
+	ppool "uniswap-simulator/lib/pool"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+func testPool(t *testing.T) *ppool.Pool {
+	t.Helper()
+	sqrtPrice, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
+	sqrtPriceX96, _ := ui.FromBig(sqrtPrice)
+	p, err := ppool.NewPool("USDC", "WETH", 500, sqrtPriceX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// history records the events of a mint, two swaps and a burn like the contract emits them
+func history(t *testing.T) ent.Slice {
+	chain := testPool(t)
+	liquidity := ui.NewInt(1_000_000_000_000_000)
+	mint0, mint1, err := chain.Mint("lp", 190000, 200000, liquidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions := ent.Slice{{Type: "Mint", ID: "mint", Timestamp: 0, Amount: liquidity, Amount0: mint0, Amount1: mint1, TickLower: 190000, TickUpper: 200000}}
+	for i, amount := range []uint64{100_000_000, 50_000_000_000_000_000} {
+		token := chain.Token0
+		if i == 1 {
+			token = chain.Token1
+		}
+		amount0, amount1, err := chain.ExactInputSwap(ui.NewInt(amount), token, ui.NewInt(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions = append(transactions, ent.Transaction{
+			Type: "Swap", ID: "swap", Timestamp: 10 * (i + 1), Amount0: amount0, Amount1: amount1,
+			SqrtPriceX96: chain.SqrtRatioX96.Clone(), Tick: chain.TickCurrent, Liquidity: chain.Liquidity.Clone(),
+		})
+	}
+	burn0, burn1, err := chain.Burn("lp", 190000, 200000, liquidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(transactions, ent.Transaction{Type: "Burn", ID: "burn", Timestamp: 30, Amount: liquidity, Amount0: burn0, Amount1: burn1, TickLower: 190000, TickUpper: 200000})
+}
+
+func TestVerify(t *testing.T) {
+	transactions := history(t)
+	report, err := Verifier{}.Run(testPool(t), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Transactions != 4 || report.Divergent != 0 || report.First != nil {
+		t.Fatalf("report %+v", report)
+	}
+
+	// The second swap recorded one unit less of token0 out and two units more liquidity
+	transactions[2].Amount0 = new(ui.Int).Add(transactions[2].Amount0, ui.NewInt(1))
+	transactions[2].Liquidity = new(ui.Int).Add(transactions[2].Liquidity, ui.NewInt(2))
+	report, err = Verifier{}.Run(testPool(t), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Divergent != 1 || report.First == nil || report.First.Index != 2 {
+		t.Fatalf("report %+v", report)
+	}
+	if fields := report.First.Fields; len(fields) != 2 || fields[0].Name != "amount0" || fields[1].Name != "liquidity" {
+		t.Errorf("fields %+v", fields)
+	}
+	report, err = Verifier{Tolerance: Tolerance{Units: 1}}.Run(testPool(t), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Divergent != 1 || len(report.First.Fields) != 1 || report.First.Fields[0].Name != "liquidity" {
+		t.Errorf("tolerance of 1 unit %+v", report.First)
+	}
+	report, err = Verifier{Tolerance: Tolerance{Units: 1, Relative: 1}}.Run(testPool(t), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Divergent != 0 {
+		t.Errorf("relative tolerance of 1 %+v", report.First)
+	}
+
+	// Without the mint the swaps find no liquidity and the burn cannot be replayed
+	report, err = Verifier{}.Run(testPool(t), transactions[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Divergent != 3 || report.Divergences[2].Error == "" {
+		t.Fatalf("report %+v", report)
+	}
+	report, err = Verifier{MaxDivergences: 1}.Run(testPool(t), transactions[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Divergent != 3 || len(report.Divergences) != 1 || report.Divergences[0].Index != 0 {
+		t.Errorf("report %+v", report)
+	}
+}
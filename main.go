@@ -1,22 +1,25 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"math"
 	"math/big"
 	"os"
+	"os/signal"
 	"path"
 	"sort"
-	"strconv"
-	"sync"
+	"strings"
+	"syscall"
 	"time"
+	"uniswap-simulator/lib/config"
 	"uniswap-simulator/lib/executor"
+	"uniswap-simulator/lib/gas"
 	ppool "uniswap-simulator/lib/pool"
 	"uniswap-simulator/lib/result"
 	strat "uniswap-simulator/lib/strategy"
+	"uniswap-simulator/lib/sweep"
 	ent "uniswap-simulator/lib/transaction"
 	ui "uniswap-simulator/uint256"
 )
@@ -30,310 +33,316 @@ var Rc = 0.0262
 var startAmount string
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verifyHistory(os.Args[2:])
+		return
+	}
 	// Parse flags
-	updateIntervalPtr := flag.Int("n", 24, "updateInterval in hours")
-	filenamePtr := flag.String("file", "1_day.json", "filename")
+	configPtr := flag.String("config", path.Join("configs", "bollinger_fill_up.json"), "run config")
+	updateIntervalPtr := flag.Int("n", 0, "updateInterval in hours, overrides the config")
+	filenamePtr := flag.String("file", "", "filename, overrides the config")
+	formatPtr := flag.String("format", "", "result format ("+strings.Join(result.Formats(), ", ")+"), overrides the config")
+	snapshotsPtr := flag.String("snapshots", "", "directory for the snapshot series of the runs, overrides the config")
+	resumePtr := flag.Bool("resume", false, "skip the runs already in the checkpoint of the output file")
+	listPtr := flag.Bool("list", false, "list the available strategies and their parameters")
 	flag.Parse()
-	filename := *filenamePtr
-	updateInterval := *updateIntervalPtr
+	if *listPtr {
+		listStrategies()
+		return
+	}
+	cfg, err := config.Load(*configPtr)
+	check(err)
+	if *updateIntervalPtr > 0 {
+		cfg.UpdateInterval = *updateIntervalPtr * 60 * 60
+	}
+	if *filenamePtr != "" {
+		cfg.Output = *filenamePtr
+	}
+	if *snapshotsPtr != "" {
+		cfg.SnapshotDir = *snapshotsPtr
+	}
+	if *formatPtr != "" {
+		cfg.Format = *formatPtr
+	}
+	writer, err := result.NewWriter(cfg.Format)
+	check(err)
+	filename := cfg.Output
 	// Log flags
-	fmt.Println("updateInterval in hours:", updateInterval)
+	fmt.Println("config:", *configPtr)
+	if len(cfg.Shared) > 0 {
+		for _, strategy := range cfg.Shared {
+			fmt.Println("shared strategy:", strategy.Name)
+		}
+	} else {
+		fmt.Println("strategy:", cfg.Strategy.Name)
+	}
+	fmt.Println("updateInterval in hours:", cfg.UpdateInterval/60/60)
 	fmt.Println("filename:", filename)
 
-	updateInterval = updateInterval * 60 * 60
-	transactions := getTransactions()
-	fmt.Println("Amount of Transactions: ", len(transactions))
-	token0 := "USDC"
-	token1 := "WETH"
-	fee := 500
-	sqrtX96big, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
-	sqrtX96, _ := ui.FromBig(sqrtX96big)
-
-	pool := ppool.NewPool(token0, token1, fee, sqrtX96)
+	poolConfigs := cfg.PoolList()
+	pools := make([]*ppool.Pool, len(poolConfigs))
+	sources := make([]ent.Source, len(poolConfigs))
+	for i, poolConfig := range poolConfigs {
+		sources[i], err = ent.OpenFile(poolConfig.DataFile)
+		check(err)
+		if !cfg.Stream {
+			sources[i] = getTransactions(poolConfig.DataFile)
+		}
+		pools[i], err = newPool(poolConfig)
+		check(err)
+	}
+	transactions := sources[0]
+	if len(sources) > 1 {
+		transactions = ent.Merge(sources...)
+	}
+	transactionCount, firstTimestamp, lastTimestamp, err := ent.Bounds(transactions)
+	check(err)
+	fmt.Println("Amount of Transactions: ", transactionCount)
 
-	startAmount0 := ui.NewInt(1_000_000) // 1 USDC
-	// From the Price One month in
-	startAmount1big, _ := new(big.Int).SetString("366874042000000", 10) // 366874042000000 wei ~= 1 USD worth of ETH
-	// out_of_sample
-	//startAmount1big, _ = new(big.Int).SetString("231104725000000", 10) // 231103715000000 wei ~= 1 USD worth of ETH
-	startAmount1, _ := ui.FromBig(startAmount1big)
-	startAmount = "2000000" // HardCoded is the easy way to do it
+	startAmount0, _ := config.ParseUint256(cfg.StartAmount0)
+	startAmount1, _ := config.ParseUint256(cfg.StartAmount1)
+	startAmount = cfg.StartAmount
 
-	startTime := transactions[0].Timestamp + 60*60*24*30
-	// For out of Sample
-	//startTime = 1637411195
+	startTime := firstTimestamp + cfg.StartOffset
+	if cfg.StartTime != 0 {
+		startTime = cfg.StartTime
+	}
 
 	fmt.Println("Start Time: ", startTime)
-	snapshotInterval := 60 * 60 // hourly
 
-	var wg sync.WaitGroup
 	start := time.Now()
 
-	amountHistorySnapshots := 100
-	mulUpperBound := IntPow(2, 16)
-	results := make([]result.RunResult, mulUpperBound-1)
-	duration := 24 * 60 * 60
-	mul := 1
-	for mul < mulUpperBound {
-		for j := 0; j < 5000 && mul < mulUpperBound; j, mul = j+1, mul+1 {
-			i := mul - 1
-			priceHistoryInterval := duration / amountHistorySnapshots
-			strategy := strat.NewBollingerBandsFillUpStrategy(startAmount0, startAmount1, pool, amountHistorySnapshots, mul)
-			execution := executor.CreateExecution(strategy, startTime, 90000000000000, updateInterval, snapshotInterval, priceHistoryInterval, transactions)
-			wg.Add(1)
-			go runAndAppend(&wg, execution, i, mul, duration, updateInterval, results)
+	var gasModel *gas.Model
+	if cfg.Gas != nil {
+		gasModel, err = gas.Load(*cfg.Gas)
+		check(err)
+	}
+	err = os.MkdirAll("results", os.ModePerm)
+	check(err)
+
+	if len(cfg.Shared) > 0 {
+		results, err := runShared(cfg, pools, transactions, startAmount0, startAmount1, startTime, gasModel)
+		check(err)
+		saveFile(writer, results, filename, firstTimestamp, lastTimestamp)
+		fmt.Println("Time: ", time.Since(start))
+		fmt.Println("Done")
+		return
+	}
+
+	space, err := newSpace(cfg)
+	check(err)
+	points, err := cfg.Sampling.Points(space)
+	check(err)
+	fmt.Println("Amount of Runs: ", len(points))
+
+	checkpointPath := path.Join("results", filename+".checkpoint.jsonl")
+	checkpoint, previous, err := sweep.OpenCheckpoint(checkpointPath, *resumePtr)
+	check(err)
+	if *resumePtr {
+		points = sweep.Remaining(points, previous)
+		fmt.Printf("Resuming from %s, %d runs done, %d left\n", checkpointPath, len(previous), len(points))
+	}
+
+	build := func(p sweep.Point) (*executor.Execution, error) {
+		params := make(strat.Params, len(p))
+		updateInterval := cfg.UpdateInterval
+		amount0, amount1 := startAmount0, startAmount1
+		for name, value := range p {
+			switch name {
+			case sweep.UpdateInterval:
+				updateInterval = int(value)
+			case sweep.Notional:
+				amount0, amount1 = scale(startAmount0, value), scale(startAmount1, value)
+			default:
+				params[name] = value
+			}
 		}
-		wg.Wait()
+		strategy, err := strat.NewMultiPool(cfg.Strategy.Name, amount0, amount1, pools, params)
+		if err != nil {
+			return nil, err
+		}
+		execution := executor.CreateExecution(strategy, startTime, cfg.EndTime, updateInterval, cfg.SnapshotInterval, cfg.PriceHistoryInterval, transactions)
+		execution.Gas = gasModel
+		execution.Liquidation = cfg.Liquidation || cfg.Capacity != nil
+		return execution, nil
+	}
+	if cfg.SnapshotDir != "" {
+		check(os.MkdirAll(cfg.SnapshotDir, os.ModePerm))
+	}
+	s := sweep.Sweep{
+		Points:        points,
+		Scheduler:     executor.NewScheduler(os.Stderr),
+		HistoryWindow: cfg.HistoryWindow,
+		Checkpoint:    checkpoint,
+		Build:         build,
+	}
+	if cfg.SnapshotTop == 0 {
+		s.SnapshotDir = cfg.SnapshotDir
+	}
+	// Stop starting new runs on SIGINT and SIGTERM (scancel) and save what is done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	results, err := s.Run(ctx)
+	check(checkpoint.Close())
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("Interrupted, saving %d of %d runs\n", len(results), len(points))
+	} else {
+		check(err)
+	}
+	results = append(previous, results...)
+	if failed := failedRuns(results); failed > 0 {
+		fmt.Printf("%d of %d runs failed, see the error column\n", failed, len(results))
 	}
 
-	transLen := len(transactions)
-	saveFile(results, filename, transactions[0].Timestamp, transactions[transLen-1].Timestamp)
+	saveFile(writer, results, filename, firstTimestamp, lastTimestamp)
+	if cfg.Capacity != nil {
+		printCapacity(results)
+	}
+
+	if cfg.SnapshotDir != "" && cfg.SnapshotTop > 0 && ctx.Err() == nil {
+		top := topPoints(results, cfg.SnapshotTop)
+		fmt.Printf("Recording snapshots of the best %d runs to %s\n", len(top), cfg.SnapshotDir)
+		topSweep := sweep.Sweep{
+			Points:        top,
+			Scheduler:     executor.NewScheduler(nil),
+			HistoryWindow: cfg.HistoryWindow,
+			Build:         build,
+			SnapshotDir:   cfg.SnapshotDir,
+		}
+		_, err := topSweep.Run(ctx)
+		check(err)
+	}
 
 	t := time.Now()
 	fmt.Println("Time: ", t.Sub(start))
 	fmt.Println("Done")
 }
-func getReturns(prices []*ui.Int) []float64 {
-	returns := make([]float64, 0, len(prices))
-	for i := 1; i < len(prices); i++ {
-		sIMinus1 := prices[i-1].ToBig()
-		sI := prices[i].ToBig()
-		sIMinus1F, _ := new(big.Float).SetInt(sIMinus1).Float64()
-		sIF, _ := new(big.Float).SetInt(sI).Float64()
-		ret := math.Log(sIF / sIMinus1F)
-		returns = append(returns, ret)
-	}
-	return returns
-}
-
-func calculateMaximumDrawdown(prices []*ui.Int) float64 {
-	if len(prices) <= 1 {
-		return 0
-	}
-	pricesFloat := make([]float64, 0, len(prices))
-	for _, price := range prices {
-		floatNumber, _ := new(big.Float).SetInt(price.ToBig()).Float64()
-		pricesFloat = append(pricesFloat, floatNumber)
-	}
-	maxPrice := pricesFloat[0]
-	maxDrawdown := 0.0
-	for _, price := range pricesFloat {
-		maxPrice = math.Max(maxPrice, price)
-		drawdown := (price - maxPrice) / maxPrice
-		maxDrawdown = math.Min(maxDrawdown, drawdown)
-	}
-	return maxDrawdown
-}
 
-// MAR is Zero
-func calculateDownDeviation(prices []*ui.Int) float64 {
-	if len(prices) <= 2 {
-		return 0
-	}
-	returns := getReturns(prices)
-	sum := 0.0
-	for _, r := range returns {
-		if r < 0 {
-			sum += r * r
+// newSpace builds the sweep space of a config.
+// Dimensions of integer parameters only take integer values.
+func newSpace(cfg *config.Config) (sweep.Space, error) {
+	definition, ok := strat.Lookup(cfg.Strategy.Name)
+	if !ok {
+		return sweep.Space{}, fmt.Errorf("unknown strategy %q", cfg.Strategy.Name)
+	}
+	types := map[string]strat.ParameterType{sweep.UpdateInterval: strat.Int, sweep.Notional: strat.Float}
+	for _, parameter := range definition.Parameters {
+		types[parameter.Name] = parameter.Type
+	}
+	dimensions := make(map[string]sweep.Dimension, len(cfg.Grid))
+	for name, dimension := range cfg.Grid {
+		parameterType, ok := types[name]
+		if !ok {
+			return sweep.Space{}, fmt.Errorf("%s has no parameter %s", cfg.Strategy.Name, name)
 		}
+		dimension.Integer = dimension.Integer || parameterType == strat.Int
+		dimensions[name] = dimension
 	}
-	n := len(returns)
-	sum = sum / float64(n)
-	downwardDeviation := math.Sqrt(sum)
-	return downwardDeviation
-
+	if cfg.Capacity != nil {
+		capacity := *cfg.Capacity
+		capacity.Geometric = true
+		dimensions[sweep.Notional] = capacity
+	}
+	return sweep.Space{Fixed: cfg.Strategy.Parameters, Dimensions: dimensions}, nil
 }
 
-// VaR 95%
-func calculateVar(prices []*ui.Int) float64 {
-	if len(prices) <= 2 {
-		return 0
-	}
-	returns := getReturns(prices)
-	sort.Float64s(returns)
-	idx := len(returns) / 20
-	var95 := returns[idx]
-	return var95
+// scale returns amount times factor, rounded down.
+// The product has the 256 bits of the amount and the 53 bits of the factor, so it is exact.
+func scale(amount *ui.Int, factor float64) *ui.Int {
+	scaled, _ := new(big.Float).SetPrec(256+53).Mul(new(big.Float).SetInt(amount.ToBig()), big.NewFloat(factor)).Int(nil)
+	amountScaled, _ := ui.FromBig(scaled)
+	return amountScaled
 }
 
-func calculateStd(prices []*ui.Int) float64 {
-	if len(prices) <= 2 {
-		return 0
-	}
-	returns := getReturns(prices)
-	n := len(returns)
-	sum1 := 0.0
-	for _, r := range returns {
-		sum1 += r * r
-	}
-	sum1 = sum1 / float64(n-1)
-	sum2 := 0.0
-	for _, r := range returns {
-		sum2 += r
-	}
-	sum2 = sum2 * sum2
-	quotient := n * (n - 1)
-	sum2 = sum2 / float64(quotient)
-
-	variance := sum1 - sum2
-	standardDeviation := math.Sqrt(variance)
-
-	return standardDeviation
+func failedRuns(results []result.RunResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	return failed
 }
 
-//goland:noinspection SpellCheckingInspection
-func runAndAppend(wg *sync.WaitGroup, execution *executor.Execution, i, mul, duration, updateInterval int, results []result.RunResult) {
-	defer wg.Done()
-	execution.Run()
-
-	pricesHourly := make([]*ui.Int, 0, len(execution.AmountUSDSnapshots))
-	pricesDaily := make([]*ui.Int, 0, len(execution.AmountUSDSnapshots)/24)
-	pricesWeekly := make([]*ui.Int, 0, len(execution.AmountUSDSnapshots)/24/7)
-	for i := 0; i < len(execution.AmountUSDSnapshots); i++ {
-		pricesHourly = append(pricesHourly, execution.AmountUSDSnapshots[i].Clone())
-		if i%24 == 0 {
-			pricesDaily = append(pricesDaily, execution.AmountUSDSnapshots[i].Clone())
-		}
-		if i%(24*7) == 0 {
-			pricesWeekly = append(pricesWeekly, execution.AmountUSDSnapshots[i].Clone())
+// topPoints returns the points of the n successful results with the highest return
+func topPoints(results []result.RunResult, n int) []sweep.Point {
+	sorted := make([]result.RunResult, 0, len(results))
+	for _, r := range results {
+		if r.Error == "" {
+			sorted = append(sorted, r)
 		}
 	}
-
-	maxDrawDown := calculateMaximumDrawdown(pricesHourly)
-
-	stdHourly := calculateStd(pricesHourly)
-	stdDaily := calculateStd(pricesDaily)
-	stdWeekly := calculateStd(pricesWeekly)
-	dDHourly := calculateDownDeviation(pricesHourly)
-	dDDaily := calculateDownDeviation(pricesDaily)
-	dDWeekly := calculateDownDeviation(pricesWeekly)
-	varHourly := calculateVar(pricesHourly)
-	varDaily := calculateVar(pricesDaily)
-	varWeekly := calculateVar(pricesWeekly)
-	res := createResult(execution, mul, duration, updateInterval, stdHourly, stdDaily, stdWeekly, dDHourly, dDDaily, dDWeekly, maxDrawDown, varHourly, varDaily, varWeekly)
-	results[i] = res
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Return > sorted[j].Return
+	})
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	points := make([]sweep.Point, n)
+	for i := range points {
+		points[i] = sorted[i].Parameters
+	}
+	return points
 }
 
-func createResult(execution *executor.Execution, mul, duration, updateInterval int, stdHourly, stdDaily, stdWeekly, dDHourly, dDDaily, dDWeekly, maxDrawDown, var95Hourly, var95Daily, var95Weekly float64) result.RunResult {
-
-	length := len(execution.AmountUSDSnapshots)
-
-	amountUSDEnd := execution.AmountUSDSnapshots[length-1]
-	amountEnd := amountUSDEnd.ToBig().String()
-
-	amountEndFloat, _ := new(big.Float).SetInt(amountUSDEnd.ToBig()).Float64()
-	amountStartFloat, _ := new(big.Float).SetInt(execution.AmountUSDSnapshots[0].ToBig()).Float64()
-	amountDiff := amountEndFloat - amountStartFloat
-	roi := amountDiff / amountStartFloat
-
-	r := result.RunResult{
-		EndAmount:     amountEnd,
-		MultiplierX10: mul,
-		//ParameterA: a,
-		HistoryWindow:           duration,
-		UpdateInterval:          updateInterval,
-		Return:                  roi,
-		StandardDeviationHourly: stdHourly,
-		StandardDeviationDaily:  stdDaily,
-		StandardDeviationWeekly: stdWeekly,
-		DownwardDeviationHourly: dDHourly,
-		DownwardDeviationDaily:  dDDaily,
-		DownwardDeviationWeekly: dDWeekly,
-		MaxDrawdown:             maxDrawDown,
-		VaR95Hourly:             var95Hourly,
-		VaR95Daily:              var95Daily,
-		VaR95Weekly:             var95Weekly,
+func listStrategies() {
+	for _, definition := range strat.List() {
+		fmt.Printf("%s: %s\n", definition.Name, definition.Description)
+		for _, p := range definition.Parameters {
+			fmt.Printf("  %-16s %-5s default %-8v range [%v, %v] %s\n", p.Name, p.Type, p.Default, p.Min, p.Max, p.Description)
+		}
 	}
-
-	return r
-
 }
 
-func saveFile(results []result.RunResult, filename string, startTime, endTime int) {
-
+// saveFile writes the results to the results directory.
+// The extension of filename is replaced by the one of the writer.
+func saveFile(writer result.Writer, results []result.RunResult, filename string, startTime, endTime int) {
+	filename = strings.TrimSuffix(filename, path.Ext(filename)) + writer.Extension()
 	filepath := path.Join("results", filename)
-	err := os.Mkdir("results", os.ModePerm)
-	if err != nil {
-		fmt.Println("Error creating directory: ", err)
-	}
 	fmt.Println("Saving to: ", filepath)
 	file, err := os.Create(filepath)
-	if err != nil {
-		panic(err)
-	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(file)
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-
+	check(err)
 	toSave := result.Save{
 		StartAmount: startAmount,
 		StartTime:   startTime,
 		EndTime:     endTime,
 		Results:     results,
 	}
-	err = encoder.Encode(toSave)
-	if err != nil {
-		return
+	err = writer.Write(file, toSave)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
+	check(err)
+}
 
+// newPool creates the pool of a config at its start price
+func newPool(poolConfig config.Pool) (*ppool.Pool, error) {
+	sqrtX96, _ := config.ParseUint256(poolConfig.SqrtPriceX96)
+	var pool *ppool.Pool
+	var err error
+	if poolConfig.TickSpacing != 0 {
+		pool, err = ppool.NewPoolWithTickSpacing(poolConfig.Token0, poolConfig.Token1, poolConfig.Fee, poolConfig.TickSpacing, sqrtX96)
+	} else {
+		pool, err = ppool.NewPool(poolConfig.Token0, poolConfig.Token1, poolConfig.Fee, sqrtX96)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pool, pool.SetFeeProtocol(poolConfig.FeeProtocol0, poolConfig.FeeProtocol1)
 }
 
-func getTransactions() []ent.Transaction {
-	filename := "transactions_insample.json"
-	filepath := path.Join("data", filename)
-	file, err := os.Open(filepath)
-	check(err)
-	value, err := ioutil.ReadAll(file)
+func getTransactions(filepath string) ent.Slice {
+	source, err := ent.OpenFile(filepath)
 	check(err)
-	var transactionsInput []ent.TransactionInput
-	err = json.Unmarshal([]byte(value), &transactionsInput)
+	transactions, err := ent.Load(source)
 	check(err)
-	var transactions []ent.Transaction
-	for _, transIn := range transactionsInput {
-		useX96, _ := strconv.ParseBool(transIn.UseX96)
-		trans := ent.Transaction{
-			transIn.Type,
-			stringToUint256(transIn.Amount),
-			stringToUint256(transIn.Amount0),
-			stringToUint256(transIn.Amount1),
-			transIn.ID,
-			stringToUint256(transIn.SqrtPriceX96),
-			transIn.Tick,
-			transIn.TickLower,
-			transIn.TickUpper,
-			transIn.Timestamp,
-			useX96,
-		}
-		transactions = append(transactions, trans)
-	}
 	return transactions
 }
 
-func stringToUint256(amount string) *ui.Int {
-	bigint := new(big.Int)
-	bigint.SetString(amount, 10)
-	uint256, _ := ui.FromBig(bigint)
-	return uint256
-}
-
-// IntPow calculates n to the mth power. Since the result is an int, it is assumed that m is a positive power
-func IntPow(n, m int) int {
-	// IDK why go doesn't have it...
-	if m == 0 {
-		return 1
-	}
-	result := n
-	for i := 2; i <= m; i++ {
-		result *= n
-	}
-	return result
-}
-
 func check(e error) {
 	if e != nil {
 		panic(e)
@@ -1,65 +1,79 @@
 package main
 
 import (
-	"fmt"
 	"math/big"
+	"path"
 	"testing"
 	cons "uniswap-simulator/lib/constants"
 	ppool "uniswap-simulator/lib/pool"
 	ui "uniswap-simulator/uint256"
 )
 
-func Test(t *testing.T) {
-	transactions := getTransactions()
-	token0 := "USDC"
-	token1 := "WETH"
-	fee := 500
+// owner of all positions of the history, whose owners are not in the transactions
+const owner = "history"
+
+// testPool returns the USDC/WETH 500 pool at the price of the first transaction of the history, without liquidity
+func testPool(t *testing.T) *ppool.Pool {
+	t.Helper()
 	sqrtX96big, _ := new(big.Int).SetString("1350174849792634181862360983626536", 10)
 	sqrtX96, _ := ui.FromBig(sqrtX96big)
+	pool, err := ppool.NewPool("USDC", "WETH", 500, sqrtX96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
 
-	pool := ppool.NewPool(token0, token1, fee, sqrtX96)
+func Test(t *testing.T) {
+	transactions := getTransactions(path.Join("data", "transactions_insample.json"))
+	pool := testPool(t)
 
 	for _, trans := range transactions {
 		var amount0, amount1 *ui.Int
+		var err error
 		switch trans.Type {
 		case "Mint":
-			amount0, amount1 = pool.MintStrategy(trans.TickLower, trans.TickUpper, trans.Amount)
+			amount0, amount1, err = pool.Mint(owner, trans.TickLower, trans.TickUpper, trans.Amount)
+			if err != nil {
+				t.Fatalf("%s %s: %v", trans.Type, trans.ID, err)
+			}
 			if !trans.Amount1.Eq(amount1) || !trans.Amount0.Eq(amount0) {
-				fmt.Printf("%d %d %d %d\n", trans.Amount1, amount1, trans.Amount0, amount0)
-				t.Errorf("Not passing sanity check")
+				t.Errorf("%s %s: amount1 %d, want %d, amount0 %d, want %d", trans.Type, trans.ID, amount1, trans.Amount1, amount0, trans.Amount0)
 			}
 
 		case "Burn":
-			amount0, amount1 = pool.BurnStrategy(trans.TickLower, trans.TickUpper, trans.Amount)
-
+			amount0, amount1, err = pool.Burn(owner, trans.TickLower, trans.TickUpper, trans.Amount)
+			if err != nil {
+				t.Fatalf("%s %s: %v", trans.Type, trans.ID, err)
+			}
 			if !trans.Amount1.Eq(amount1) || !trans.Amount0.Eq(amount0) {
-				fmt.Printf("%d %d %d %d\n", trans.Amount1, amount1, trans.Amount0, amount0)
-				t.Errorf("Not passing sanity check")
+				t.Errorf("%s %s: amount1 %d, want %d, amount0 %d, want %d", trans.Type, trans.ID, amount1, trans.Amount1, amount0, trans.Amount0)
 			}
 		case "Swap":
-
-			if trans.Amount0.Sign() > 0 {
-				if trans.UseX96 {
-					amount0, amount1 = pool.ExactInputSwap(trans.Amount0, pool.Token0, trans.SqrtPriceX96)
-				} else {
-					amount0, amount1 = pool.ExactInputSwap(trans.Amount0, pool.Token0, cons.Zero)
-				}
-			} else if trans.Amount1.Sign() > 0 {
-				if trans.UseX96 {
-					amount0, amount1 = pool.ExactInputSwap(trans.Amount1, pool.Token1, trans.SqrtPriceX96)
-				} else {
-					amount0, amount1 = pool.ExactInputSwap(trans.Amount1, pool.Token1, cons.Zero)
-				}
+			amountIn, token := trans.Amount0, pool.Token0
+			if trans.Amount0.Sign() <= 0 {
+				amountIn, token = trans.Amount1, pool.Token1
+			}
+			if amountIn.Sign() <= 0 {
+				continue
+			}
+			limit := cons.Zero
+			if trans.UseX96 {
+				limit = trans.SqrtPriceX96
+			}
+			amount0, amount1, err = pool.ExactInputSwap(amountIn, token, limit)
+			if err != nil {
+				t.Fatalf("%s %s: %v", trans.Type, trans.ID, err)
 			}
 			if !trans.Amount1.Eq(amount1) || !trans.Amount0.Eq(amount0) || !trans.SqrtPriceX96.Eq(pool.SqrtRatioX96) || trans.Tick != pool.TickCurrent {
-				fmt.Printf("%d %d %d %d\n", trans.Amount1, amount1, trans.Amount0, amount0)
-				fmt.Printf("%d %d %d %d\n", trans.SqrtPriceX96, pool.SqrtRatioX96, trans.Tick, pool.TickCurrent)
-				pool.TickData.Print()
-				t.Errorf("Not passing sanity check")
+				t.Errorf("%s %s: amount1 %d, want %d, amount0 %d, want %d", trans.Type, trans.ID, amount1, trans.Amount1, amount0, trans.Amount0)
+				t.Logf("sqrtPriceX96 %d, want %d, tick %d, want %d", pool.SqrtRatioX96, trans.SqrtPriceX96, pool.TickCurrent, trans.Tick)
 			}
 
 		case "Flash":
-			pool.Flash(trans.Amount0, trans.Amount1)
+			if err = pool.Flash(trans.Amount0, trans.Amount1); err != nil {
+				t.Fatalf("%s %s: %v", trans.Type, trans.ID, err)
+			}
 		}
 	}
 	if pool.SqrtRatioX96.ToBig().String() != "1204434112404346008547779933205831" {
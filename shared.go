@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"uniswap-simulator/lib/config"
+	"uniswap-simulator/lib/executor"
+	"uniswap-simulator/lib/gas"
+	ppool "uniswap-simulator/lib/pool"
+	"uniswap-simulator/lib/result"
+	strat "uniswap-simulator/lib/strategy"
+	"uniswap-simulator/lib/sweep"
+	ent "uniswap-simulator/lib/transaction"
+	ui "uniswap-simulator/uint256"
+)
+
+// runShared replays the shared strategies of the config in one instance of the pools.
+// The owner of the i-th strategy is "<i>_<name>".
+func runShared(cfg *config.Config, pools []*ppool.Pool, transactions ent.Source, startAmount0, startAmount1 *ui.Int, startTime int, gasModel *gas.Model) ([]result.RunResult, error) {
+	executions := make([]*executor.Execution, len(cfg.Shared))
+	for i, strategyConfig := range cfg.Shared {
+		owner := fmt.Sprintf("%d_%s", i, strategyConfig.Name)
+		strategy, err := strat.NewShared(strategyConfig.Name, owner, startAmount0, startAmount1, pools, strategyConfig.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		executions[i] = executor.CreateExecution(strategy, startTime, cfg.EndTime, cfg.UpdateInterval, cfg.SnapshotInterval, cfg.PriceHistoryInterval, transactions)
+		executions[i].Gas = gasModel
+		executions[i].Liquidation = cfg.Liquidation
+	}
+	fmt.Printf("Replaying %d strategies in a shared pool\n", len(executions))
+	shared := executor.Shared{Executions: executions, Transactions: transactions}
+	if err := shared.Run(); err != nil {
+		return nil, err
+	}
+
+	results := make([]result.RunResult, len(executions))
+	for i, execution := range executions {
+		results[i] = sweep.NewResult(execution, cfg.Shared[i].Parameters, cfg.HistoryWindow)
+		results[i].Owner = execution.Strategy.GetOwner()
+	}
+	return results, nil
+}
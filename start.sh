@@ -1,6 +1,6 @@
 #!/bin/bash
 
-go build main.go
+go build -o main .
 sbatch run.sh 2 2_hours.json
 sbatch run.sh 6 6_hours.json
 sbatch run.sh 24 1_day.json
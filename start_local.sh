@@ -1,6 +1,6 @@
 #!/bin/bash
 
-go build main.go
+go build -o main .
 ./main -n=2 -file=2_hours.json
 ./main -n=6 -file=6_hours.json
 ./main -n=24 -file=1_day.json
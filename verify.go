@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"uniswap-simulator/lib/config"
+	ent "uniswap-simulator/lib/transaction"
+	"uniswap-simulator/lib/verify"
+)
+
+// verifyHistory replays the data files of a config without strategies and reports where they diverge from the pool
+func verifyHistory(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPtr := flags.String("config", path.Join("configs", "bollinger_fill_up.json"), "run config with the pools and data files")
+	relativePtr := flags.Float64("relative", 0, "relative tolerance of amounts, prices and liquidity")
+	unitsPtr := flags.Uint64("units", 0, "absolute tolerance of amounts, prices and liquidity")
+	ticksPtr := flags.Int("ticks", 0, "tolerance of the tick")
+	maxPtr := flags.Int("max", 0, "divergences kept per pool in the report, 0 keeps all")
+	outPtr := flags.String("out", "", "JSON file for the report of every data file")
+	check(flags.Parse(args))
+
+	cfg, err := config.Load(*configPtr)
+	check(err)
+	verifier := verify.Verifier{
+		Tolerance:      verify.Tolerance{Relative: *relativePtr, Units: *unitsPtr, Ticks: *ticksPtr},
+		MaxDivergences: *maxPtr,
+	}
+	reports := make(map[string]*verify.Report)
+	divergent := 0
+	for _, poolConfig := range cfg.PoolList() {
+		pool, err := newPool(poolConfig)
+		check(err)
+		source, err := ent.OpenFile(poolConfig.DataFile)
+		check(err)
+		report, err := verifier.Run(pool, source)
+		check(err)
+		reports[poolConfig.DataFile] = report
+		divergent += report.Divergent
+
+		fmt.Printf("%s: %d of %d transactions diverge\n", poolConfig.DataFile, report.Divergent, report.Transactions)
+		if first := report.First; first != nil {
+			fmt.Printf("first: %s %s #%d at %d", first.Type, first.ID, first.Index, first.Timestamp)
+			if first.Error != "" {
+				fmt.Printf(": %s", first.Error)
+			}
+			fmt.Println()
+			for _, field := range first.Fields {
+				fmt.Printf("  %s recorded %s, replayed %s\n", field.Name, field.Recorded, field.Replayed)
+			}
+		}
+	}
+
+	if *outPtr != "" {
+		file, err := os.Create(*outPtr)
+		check(err)
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		check(encoder.Encode(reports))
+		check(file.Close())
+	}
+	if divergent > 0 {
+		os.Exit(1)
+	}
+}